@@ -0,0 +1,264 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// bounds on how long a zone's nameservers are cached, regardless of the NS ttl
+	minZoneTTL = time.Minute
+	maxZoneTTL = 24 * time.Hour
+	// discovery is shared by every lookup waiting on the zone so it isn't bound by any single one of them
+	discoveryTimeout = 10 * time.Second
+)
+
+var DNSNoAuthoritativeServersError = errors.New("dnsresolver: no authoritative nameservers found")
+
+// zoneServers is a cache entry, servers and err are only set once ready is closed
+type zoneServers struct {
+	ready   chan struct{}
+	servers []string
+	expires time.Time
+	err     error
+}
+
+func (z *zoneServers) expired(now time.Time) bool {
+	select {
+	case <-z.ready:
+		return now.After(z.expires)
+	default:
+		// still being discovered
+		return false
+	}
+}
+
+// parentZone returns the zone a domain is delegated from, ie "example.net" -> "net" and "example.co.uk" -> "co.uk"
+func parentZone(domain string) string {
+	_, zone, _ := strings.Cut(strings.TrimSuffix(domain, "."), ".")
+	return zone
+}
+
+func sameName(name dnsmessage.Name, domain string) bool {
+	return strings.EqualFold(name.String(), fqdn(domain))
+}
+
+// directTransport is the transport used for authoritative nameservers, which only speak plain DNS
+func (r *Resolver) directTransport() Transport {
+	if r.transport == TransportTCP {
+		return TransportTCP
+	}
+	return TransportUDP
+}
+
+func (r *Resolver) lookupAuthoritative(ctx context.Context, domain string) (answer, error) {
+	servers, err := r.zoneServers(ctx, parentZone(domain))
+	if err != nil {
+		return answer{}, err
+	}
+
+	var (
+		servFail bool
+		lastErr  error
+	)
+	for _, i := range r.order(len(servers)) {
+		resp, err := r.query(ctx, r.directTransport(), servers[i], domain, dnsmessage.TypeNS, false)
+		if err != nil {
+			lastErr = err
+		} else if a := referralStatus(resp, domain); a.status == StatusServFail {
+			servFail = true
+		} else {
+			return a, nil
+		}
+
+		// no point asking anyone else if we ran out of time
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if servFail {
+		return answer{status: StatusServFail}, nil
+	}
+	return answer{}, lastErr
+}
+
+// referralStatus interprets a non-recursive answer from the parent zone
+func referralStatus(resp dnsmessage.Message, domain string) answer {
+	switch resp.Header.RCode {
+	case dnsmessage.RCodeNameError:
+		return answer{status: StatusNXDomain, ttl: negativeTTL(resp)}
+	case dnsmessage.RCodeServerFailure:
+		return answer{status: StatusServFail}
+	}
+
+	// the delegation shows up in the authority section, unless the server happens to serve the child zone too
+	for _, rr := range resp.Authorities {
+		if rr.Header.Type == dnsmessage.TypeNS && sameName(rr.Header.Name, domain) {
+			return answer{status: StatusReferral, ttl: recordTTL(resp.Authorities)}
+		}
+	}
+	for _, rr := range resp.Answers {
+		if rr.Header.Type == dnsmessage.TypeNS && sameName(rr.Header.Name, domain) {
+			return answer{status: StatusDelegated, ttl: recordTTL(resp.Answers)}
+		}
+	}
+	// NOERROR without a delegation, the name is in the zone but not delegated
+	return answer{status: StatusExists, ttl: negativeTTL(resp)}
+}
+
+// zoneServers returns the cached authoritative nameservers for zone, discovering them if needed
+func (r *Resolver) zoneServers(ctx context.Context, zone string) ([]string, error) {
+	r.zonesMu.Lock()
+	entry, ok := r.zones[zone]
+	if !ok || entry.expired(time.Now()) {
+		entry = &zoneServers{ready: make(chan struct{})}
+		r.zones[zone] = entry
+		r.zonesMu.Unlock()
+
+		go func() {
+			dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), discoveryTimeout)
+			defer cancel()
+
+			entry.servers, entry.expires, entry.err = r.discover(dctx, zone)
+			if entry.err != nil {
+				// failures aren't cached, the next lookup tries again
+				r.zonesMu.Lock()
+				if r.zones[zone] == entry {
+					delete(r.zones, zone)
+				}
+				r.zonesMu.Unlock()
+			}
+			close(entry.ready)
+		}()
+	} else {
+		r.zonesMu.Unlock()
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.servers, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// discover asks the configured nameservers who is authoritative for zone, returns their addresses and when to forget
+// them.
+func (r *Resolver) discover(ctx context.Context, zone string) ([]string, time.Time, error) {
+	resp, err := r.ask(ctx, zone, dnsmessage.TypeNS)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	ttl := maxZoneTTL
+	var hosts []string
+	for _, rr := range resp.Answers {
+		ns, ok := rr.Body.(*dnsmessage.NSResource)
+		if !ok || !sameName(rr.Header.Name, zone) {
+			continue
+		}
+		hosts = append(hosts, ns.NS.String())
+		ttl = min(ttl, time.Duration(rr.Header.TTL)*time.Second)
+	}
+	ttl = max(ttl, minZoneTTL)
+
+	// recursive resolvers usually include glue, only look up what's missing. ipv6 only if asked for since its
+	// connectivity can't be assumed.
+	glue4 := make(map[string][]netip.Addr)
+	glue6 := make(map[string][]netip.Addr)
+	for _, rr := range resp.Additionals {
+		host := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			glue4[host] = append(glue4[host], netip.AddrFrom4(body.A))
+		case *dnsmessage.AAAAResource:
+			glue6[host] = append(glue6[host], netip.AddrFrom16(body.AAAA))
+		}
+	}
+
+	// ipv4 addresses go first so ipv6 is only relied on when ipv4 fails, at least with the failover strategy
+	var v4, v6 []string
+	for _, host := range hosts {
+		addrs, ok := glue4[strings.ToLower(host)]
+		if !ok {
+			// other nameservers may still be usable if this one can't be resolved
+			addrs, _ = r.resolveAddrs(ctx, host, dnsmessage.TypeA)
+		}
+		v4 = append(v4, r.authoritativeAddrs(addrs)...)
+
+		if !r.authoritativeIPv6 {
+			continue
+		}
+		addrs, ok = glue6[strings.ToLower(host)]
+		if !ok {
+			addrs, _ = r.resolveAddrs(ctx, host, dnsmessage.TypeAAAA)
+		}
+		v6 = append(v6, r.authoritativeAddrs(addrs)...)
+	}
+	servers := append(v4, v6...)
+
+	if len(servers) == 0 {
+		return nil, time.Time{}, &net.DNSError{
+			Err:         DNSNoAuthoritativeServersError.Error(),
+			Name:        zone,
+			IsTemporary: true,
+			UnwrapErr:   DNSNoAuthoritativeServersError,
+		}
+	}
+	return servers, time.Now().Add(ttl), nil
+}
+
+// authoritativeAddrs turns nameserver addresses into the host:port they're asked on
+func (r *Resolver) authoritativeAddrs(addrs []netip.Addr) []string {
+	servers := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		servers = append(servers, net.JoinHostPort(addr.String(), r.authoritativePort))
+	}
+	return servers
+}
+
+// resolveAddrs looks up the A or AAAA records of host
+func (r *Resolver) resolveAddrs(ctx context.Context, host string, qtype dnsmessage.Type) ([]netip.Addr, error) {
+	resp, err := r.ask(ctx, host, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	for _, rr := range resp.Answers {
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, netip.AddrFrom4(body.A))
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, netip.AddrFrom16(body.AAAA))
+		}
+	}
+	return addrs, nil
+}
+
+// ask sends a recursive query to the configured nameservers, returning the first successful response
+func (r *Resolver) ask(ctx context.Context, name string, qtype dnsmessage.Type) (dnsmessage.Message, error) {
+	var lastErr error
+	for _, i := range r.order(len(r.nameservers)) {
+		resp, err := r.query(ctx, r.transport, r.nameservers[i], name, qtype, true)
+		if err == nil && resp.Header.RCode == dnsmessage.RCodeSuccess {
+			return resp, nil
+		}
+		if err == nil {
+			err = rcodeError(name, r.nameservers[i], resp.Header.RCode)
+		}
+		lastErr = err
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return dnsmessage.Message{}, lastErr
+}
@@ -0,0 +1,150 @@
+package dnsresolver
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// no answer is trusted for longer than this, whatever its TTL says
+const maxCacheTTL = 24 * time.Hour
+
+type (
+	// Cache is a bounded, least recently used cache of lookup answers which expire with their TTLs. It is safe for
+	// concurrent use and can be shared between resolvers.
+	Cache struct {
+		size int
+
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		lru     list.List // front is the most recently used
+
+		hits   atomic.Uint64
+		misses atomic.Uint64
+	}
+
+	CacheEntry struct {
+		Domain    string
+		Status    Status
+		ExpiresAt time.Time
+	}
+
+	CacheStats struct {
+		Hits    uint64
+		Misses  uint64
+		Entries int
+	}
+)
+
+// NewCache returns a cache holding at most size answers
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    max(size, 1),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached status of domain if it hasn't expired by now
+func (c *Cache) Get(domain string, now time.Time) (Status, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[domain]
+	if !ok {
+		c.misses.Add(1)
+		return "", false
+	}
+
+	entry := el.Value.(CacheEntry)
+	if !now.Before(entry.ExpiresAt) {
+		c.lru.Remove(el)
+		delete(c.entries, domain)
+		c.misses.Add(1)
+		return "", false
+	}
+
+	c.lru.MoveToFront(el)
+	c.hits.Add(1)
+	return entry.Status, true
+}
+
+// Put caches status for domain until expiresAt, evicting the least recently used answer if the cache is full
+func (c *Cache) Put(domain string, status Status, expiresAt time.Time) {
+	expiresAt = minTime(expiresAt, time.Now().Add(maxCacheTTL))
+	entry := CacheEntry{Domain: domain, Status: status, ExpiresAt: expiresAt}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[domain]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[domain] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(CacheEntry).Domain)
+	}
+}
+
+// Entries returns every answer which is still valid at now, most recently used first, eg to persist between runs
+func (c *Cache) Entries(now time.Time) []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CacheEntry, 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(CacheEntry); now.Before(entry.ExpiresAt) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	n := c.lru.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: n,
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// recordTTL is the smallest TTL amongst rrs, ie how long all of them are valid for
+func recordTTL(rrs []dnsmessage.Resource) time.Duration {
+	if len(rrs) == 0 {
+		return 0
+	}
+	ttl := rrs[0].Header.TTL
+	for _, rr := range rrs[1:] {
+		ttl = min(ttl, rr.Header.TTL)
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// negativeTTL is how long NXDOMAIN or NODATA can be cached for, the lesser of the SOA's TTL and minimum (RFC 2308).
+// Without an SOA it can't be cached at all.
+func negativeTTL(resp dnsmessage.Message) time.Duration {
+	for _, rr := range resp.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			return time.Duration(min(rr.Header.TTL, soa.MinTTL)) * time.Second
+		}
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package dnsresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2)
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	c.Put("a.test", StatusExists, expiresAt)
+	c.Put("b.test", StatusExists, expiresAt)
+	// a is used so b is now the least recent
+	if _, ok := c.Get("a.test", now); !ok {
+		t.Fatal("a.test missing before the cache was full")
+	}
+	c.Put("c.test", StatusExists, expiresAt)
+
+	for domain, want := range map[string]bool{"a.test": true, "b.test": false, "c.test": true} {
+		if _, ok := c.Get(domain, now); ok != want {
+			t.Errorf("Get(%q) cached = %v, want %v", domain, ok, want)
+		}
+	}
+	if got := c.Stats().Entries; got != 2 {
+		t.Errorf("cache holds %d entries, want 2", got)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := NewCache(10)
+	now := time.Now()
+	c.Put("a.test", StatusReferral, now.Add(time.Minute))
+	c.Put("b.test", StatusReferral, now.Add(time.Hour))
+
+	if got, ok := c.Get("a.test", now.Add(time.Minute-time.Second)); !ok || got != StatusReferral {
+		t.Fatalf("Get() before expiry = %q, %v, want %q, true", got, ok, StatusReferral)
+	}
+	if entries := c.Entries(now.Add(time.Minute)); len(entries) != 1 || entries[0].Domain != "b.test" {
+		t.Errorf("Entries() at expiry = %+v, want only b.test", entries)
+	}
+	if _, ok := c.Get("a.test", now.Add(time.Minute)); ok {
+		t.Fatal("Get() at expiry still returned the answer")
+	}
+	// and the expired answer is dropped rather than left taking up room
+	if got := c.Stats().Entries; got != 1 {
+		t.Errorf("cache holds %d entries after expiry, want 1", got)
+	}
+}
+
+func TestCacheClampsTTL(t *testing.T) {
+	c := NewCache(10)
+	before := time.Now()
+	c.Put("a.test", StatusExists, before.Add(7*maxCacheTTL))
+
+	entries := c.Entries(before)
+	if len(entries) != 1 {
+		t.Fatalf("Entries() = %+v, want a.test", entries)
+	}
+	if limit := time.Now().Add(maxCacheTTL); entries[0].ExpiresAt.After(limit) {
+		t.Errorf("a.test expires at %v, want no later than %v", entries[0].ExpiresAt, limit)
+	}
+	if _, ok := c.Get("a.test", before.Add(maxCacheTTL+time.Second)); ok {
+		t.Error("answer was still cached past maxCacheTTL")
+	}
+}
+
+func TestLookupAnswersAreCachedForTheirTTL(t *testing.T) {
+	tests := []struct {
+		domain string
+		status Status
+		// the stub's NS records have a TTL of 300s, negative answers the SOA's minimum of 60s
+		ttl time.Duration
+		// NODATA is followed up with an SOA query
+		queries int
+	}{
+		{"taken.test", StatusDelegated, 300 * time.Second, 1},
+		{"nx.test", StatusNXDomain, 60 * time.Second, 1},
+		{"nodata.test", StatusExists, 60 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			log := &queryLog{}
+			s := startStubServer(t, "a", log)
+			cache := NewCache(10)
+			r, err := New(Config{
+				Timeout:     2 * time.Second,
+				Nameservers: []string{s.udpAddr},
+				Cache:       cache,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := time.Now()
+			for range 2 {
+				if got, err := r.Lookup(context.Background(), tt.domain); err != nil || got != tt.status {
+					t.Fatalf("Lookup() = %q, %v, want %q", got, err, tt.status)
+				}
+			}
+			after := time.Now()
+
+			if got := len(log.get()); got != tt.queries {
+				t.Errorf("nameserver was asked %d times, want %d", got, tt.queries)
+			}
+			entries := cache.Entries(before)
+			if len(entries) != 1 {
+				t.Fatalf("cache entries = %+v, want one", entries)
+			}
+			if at := entries[0].ExpiresAt; at.Before(before.Add(tt.ttl)) || at.After(after.Add(tt.ttl)) {
+				t.Errorf("answer expires at %v, want %v after it was looked up", at, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestLookupNegativeAnswersAreCached(t *testing.T) {
+	log := &queryLog{}
+	s := startStubServer(t, "a", log)
+	r, err := New(Config{
+		Timeout:     2 * time.Second,
+		Nameservers: []string{s.udpAddr},
+		Cache:       NewCache(10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if got, err := r.Lookup(context.Background(), "nx.test"); err != nil || got != StatusNXDomain {
+			t.Fatalf("Lookup() = %q, %v, want %q", got, err, StatusNXDomain)
+		}
+	}
+	if got := len(log.get()); got != 1 {
+		t.Errorf("nameserver was asked %d times, want 1", got)
+	}
+}
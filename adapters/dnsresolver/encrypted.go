@@ -0,0 +1,97 @@
+package dnsresolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// media type of DNS wire format messages over HTTPS, see RFC 8484
+const dnsMessageContentType = "application/dns-message"
+
+// responses are capped at the largest possible DNS message
+const maxMessageSize = 1 << 16
+
+var DNSHTTPStatusError = errors.New("dnsresolver: unexpected http status")
+
+// tlsConfigFor returns the tls config used to connect to a DoT server, verifying it against its own host unless told
+// otherwise
+func (r *Resolver) tlsConfigFor(server string) *tls.Config {
+	cfg := &tls.Config{}
+	if r.tlsConfig != nil {
+		cfg = r.tlsConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(server)
+	}
+	return cfg
+}
+
+/** exchangeHTTPS sends query to a DoH endpoint as described in RFC 8484, either POSTed as the body or over GET in the
+ * dns query parameter. Over GET the ID is 0 so identical queries share the same url and can be cached.
+ */
+func (r *Resolver) exchangeHTTPS(ctx context.Context, endpoint string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	if r.httpMethod == http.MethodGet {
+		query.Header.ID = 0
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var req *http.Request
+	if r.httpMethod == http.MethodGet {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+		q := u.Query()
+		q.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+		u.RawQuery = q.Encode()
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(packed))
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+		req.Header.Set("Content-Type", dnsMessageContentType)
+	}
+	req.Header.Set("Accept", dnsMessageContentType)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return dnsmessage.Message{}, fmt.Errorf("%w: %s", DNSHTTPStatusError, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(body); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if !matches(query, msg) {
+		return dnsmessage.Message{}, DNSResponseMismatchError
+	}
+	return msg, nil
+}
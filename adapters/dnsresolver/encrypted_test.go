@@ -0,0 +1,239 @@
+package dnsresolver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// startDoHServer serves s over RFC 8484 and records the method of every request
+func startDoHServer(t *testing.T, s *stubServer, methods *queryLog) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		methods.add(req.Method)
+		if req.Header.Get("Accept") != dnsMessageContentType {
+			http.Error(w, "bad accept", http.StatusNotAcceptable)
+			return
+		}
+
+		var packed []byte
+		switch req.Method {
+		case http.MethodGet:
+			var err error
+			packed, err = base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// RFC 8484 recommends an ID of 0 so GET requests cache well
+			if len(packed) < 2 || binary.BigEndian.Uint16(packed) != 0 {
+				http.Error(w, "non zero id", http.StatusBadRequest)
+				return
+			}
+		case http.MethodPost:
+			if req.Header.Get("Content-Type") != dnsMessageContentType {
+				http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+				return
+			}
+			packed, _ = io.ReadAll(req.Body)
+		default:
+			http.Error(w, "bad method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := s.respond(packed)
+		if resp == nil {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLookupHTTPS(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		t.Run(method, func(t *testing.T) {
+			methods := &queryLog{}
+			srv := startDoHServer(t, &stubServer{name: "doh", log: &queryLog{}}, methods)
+
+			r, err := New(Config{
+				Timeout:     2 * time.Second,
+				Nameservers: []string{srv.URL + "/dns-query"},
+				Transport:   TransportHTTPS,
+				HTTPClient:  srv.Client(),
+				HTTPMethod:  method,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for domain, want := range map[string]Status{
+				"taken.test":  StatusDelegated,
+				"nx.test":     StatusNXDomain,
+				"nodata.test": StatusExists,
+			} {
+				got, err := r.Lookup(context.Background(), domain)
+				if err != nil {
+					t.Fatalf("Lookup(%q) error = %v", domain, err)
+				}
+				if got != want {
+					t.Errorf("Lookup(%q) = %q, want %q", domain, got, want)
+				}
+			}
+
+			for _, got := range methods.get() {
+				if got != method {
+					t.Fatalf("request methods = %v, want only %s", methods.get(), method)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupHTTPSStatusError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{
+		Timeout:     2 * time.Second,
+		Nameservers: []string{srv.URL},
+		Transport:   TransportHTTPS,
+		HTTPClient:  srv.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Lookup(context.Background(), "taken.test"); !errors.Is(err, DNSHTTPStatusError) {
+		t.Errorf("Lookup() error = %v, want %v", err, DNSHTTPStatusError)
+	}
+}
+
+func TestNewRejectsUnknownHTTPMethod(t *testing.T) {
+	_, err := New(Config{Nameservers: []string{"https://dns.test/dns-query"}, Transport: TransportHTTPS, HTTPMethod: "PUT"})
+	if !errors.Is(err, DNSHTTPMethodError) {
+		t.Errorf("New() error = %v, want %v", err, DNSHTTPMethodError)
+	}
+}
+
+func TestLookupTLS(t *testing.T) {
+	// borrow httptest's certificate, which is valid for 127.0.0.1, along with a client config trusting it
+	certSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certSrv.Close()
+	clientConfig := certSrv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certSrv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	log := &queryLog{}
+	s := &stubServer{name: "dot", log: log}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// a connection may carry several length prefixed queries (RFC 7858)
+				for {
+					var length [2]byte
+					if _, err := io.ReadFull(conn, length[:]); err != nil {
+						return
+					}
+					buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+					if _, err := io.ReadFull(conn, buf); err != nil {
+						return
+					}
+					resp := s.respond(buf)
+					framed := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+					if _, err := conn.Write(append(framed, resp...)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	r, err := New(Config{
+		Timeout:     2 * time.Second,
+		Nameservers: []string{net.JoinHostPort("127.0.0.1", port)},
+		Transport:   TransportTLS,
+		TLSConfig:   &tls.Config{RootCAs: clientConfig.RootCAs},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for domain, want := range map[string]Status{
+		"taken.test":    StatusDelegated,
+		"nx.test":       StatusNXDomain,
+		"servfail.test": StatusServFail,
+	} {
+		got, err := r.Lookup(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", domain, err)
+		}
+		if got != want {
+			t.Errorf("Lookup(%q) = %q, want %q", domain, got, want)
+		}
+	}
+	if got := log.get(); !slices.Contains(got, "dot taken.test.") {
+		t.Errorf("queries = %v, want the stub to have been asked", got)
+	}
+}
+
+func TestLookupTLSUntrusted(t *testing.T) {
+	certSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certSrv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certSrv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	// the system roots don't trust httptest's certificate
+	r, err := New(Config{
+		Timeout:     2 * time.Second,
+		Nameservers: []string{ln.Addr().String()},
+		Transport:   TransportTLS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Lookup(context.Background(), "taken.test"); err == nil {
+		t.Error("Lookup() against an untrusted certificate succeeded")
+	}
+}
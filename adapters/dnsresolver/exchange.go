@@ -0,0 +1,187 @@
+package dnsresolver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/khinshankhan/nomex/platform/netx"
+)
+
+const (
+	// advertised EDNS0 buffer size, the DNS flag day 2020 recommendation to avoid fragmentation
+	ednsBufferSize = 1232
+	// fallback deadline for a single exchange when ctx doesn't have one
+	defaultExchangeTimeout = 5 * time.Second
+)
+
+var (
+	DNSResponseMismatchError = errors.New("dnsresolver: response doesn't match query")
+	DNSMalformedNameError    = errors.New("dnsresolver: malformed domain name")
+)
+
+// fqdn appends the root label if missing, ie "example.net" -> "example.net."
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func newQuery(name string, qtype dnsmessage.Type, recursive bool) (dnsmessage.Message, error) {
+	n, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return dnsmessage.Message{}, errors.Join(DNSMalformedNameError, err)
+	}
+
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(ednsBufferSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	return dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.Uint32()),
+			RecursionDesired: recursive,
+		},
+		Questions: []dnsmessage.Question{
+			{Name: n, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: opt, Body: &dnsmessage.OPTResource{}},
+		},
+	}, nil
+}
+
+// exchange sends query to server over transport, retrying over tcp if a udp response comes back truncated.
+func (r *Resolver) exchange(
+	ctx context.Context,
+	transport Transport,
+	server string,
+	query dnsmessage.Message,
+) (dnsmessage.Message, error) {
+	if transport == TransportHTTPS {
+		return r.exchangeHTTPS(ctx, server, query)
+	}
+
+	resp, err := r.exchangeOnce(ctx, transport, server, query)
+	if err == nil && transport == TransportUDP && resp.Header.Truncated {
+		return r.exchangeOnce(ctx, TransportTCP, server, query)
+	}
+	return resp, err
+}
+
+func (r *Resolver) exchangeOnce(
+	ctx context.Context,
+	transport Transport,
+	server string,
+	query dnsmessage.Message,
+) (dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	conn, err := r.dial(ctx, transport, server)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+
+	unbind, err := netx.BindContext(ctx, conn, defaultExchangeTimeout)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer unbind()
+
+	if transport == TransportUDP {
+		return roundTripPacket(conn, packed, query)
+	}
+	return roundTripStream(conn, packed, query)
+}
+
+func (r *Resolver) dial(ctx context.Context, transport Transport, server string) (net.Conn, error) {
+	if transport == TransportTLS {
+		d := tls.Dialer{Config: r.tlsConfigFor(server)}
+		return d.DialContext(ctx, "tcp", server)
+	}
+
+	d := net.Dialer{}
+	return d.DialContext(ctx, string(transport), server)
+}
+
+func roundTripPacket(conn net.Conn, packed []byte, query dnsmessage.Message) (dnsmessage.Message, error) {
+	if _, err := conn.Write(packed); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	buf := make([]byte, ednsBufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil {
+			// ignore garbage, the real response may still arrive
+			continue
+		}
+		// stray or spoofed responses are dropped rather than trusted
+		if !matches(query, resp) {
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func roundTripStream(conn net.Conn, packed []byte, query dnsmessage.Message) (dnsmessage.Message, error) {
+	framed := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(framed, uint16(len(packed)))
+	copy(framed[2:], packed)
+	if _, err := conn.Write(framed); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	return readStreamResponse(conn, query)
+}
+
+// readStreamResponse reads a single length prefixed response, as used by tcp and dns over tls (RFC 7858)
+func readStreamResponse(r io.Reader, query dnsmessage.Message) (dnsmessage.Message, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if !matches(query, resp) {
+		return dnsmessage.Message{}, DNSResponseMismatchError
+	}
+	return resp, nil
+}
+
+func matches(query, resp dnsmessage.Message) bool {
+	if !resp.Header.Response || resp.Header.ID != query.Header.ID {
+		return false
+	}
+	if len(resp.Questions) != 1 || len(query.Questions) != 1 {
+		return false
+	}
+	q, r := query.Questions[0], resp.Questions[0]
+	return q.Type == r.Type && q.Class == r.Class && strings.EqualFold(q.Name.String(), r.Name.String())
+}
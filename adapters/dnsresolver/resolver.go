@@ -2,47 +2,424 @@ package dnsresolver
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Strategy decides which nameserver is asked first
+type Strategy string
+
+const (
+	// StrategyRoundRobin spreads lookups across nameservers, moving on to the next one if a lookup fails
+	StrategyRoundRobin Strategy = "round-robin"
+	// StrategyFailover always asks the first nameserver, only moving on to the next one if a lookup fails
+	StrategyFailover Strategy = "failover"
+)
+
+// Transport is the network lookups are sent over
+type Transport string
+
+const (
+	TransportUDP Transport = "udp"
+	TransportTCP Transport = "tcp"
+	// TransportTLS is DNS over TLS (RFC 7858)
+	TransportTLS Transport = "tls"
+	// TransportHTTPS is DNS over HTTPS (RFC 8484), nameservers are the URLs of the endpoints
+	TransportHTTPS Transport = "https"
+)
+
+var (
+	DNSUnknownTransportError = errors.New("dnsresolver: unknown transport")
+	DNSUnknownStrategyError  = errors.New("dnsresolver: unknown strategy")
+	DNSUnknownModeError      = errors.New("dnsresolver: unknown mode")
+	DNSNameserverError       = errors.New("dnsresolver: invalid nameserver")
+	DNSNoNameserversError    = errors.New("dnsresolver: transport requires nameservers to be configured")
+	DNSHTTPMethodError       = errors.New("dnsresolver: http method must be GET or POST")
+)
+
+// defaultPorts are the ports nameservers listen on for each transport, https uses urls instead
+var defaultPorts = map[Transport]string{
+	TransportUDP: "53",
+	TransportTCP: "53",
+	TransportTLS: "853",
+}
+
+// Status is what DNS had to say about a name
+type Status string
+
+const (
+	// StatusDelegated means the name has NS or SOA records, ie it's a zone someone registered
+	StatusDelegated Status = "delegated"
+	// StatusExists means the name exists (NOERROR) but has neither NS nor SOA records
+	StatusExists Status = "exists"
+	// StatusReferral means the parent zone's nameservers referred us to the name's own nameservers
+	StatusReferral Status = "referral"
+	// StatusNXDomain means the name doesn't exist
+	StatusNXDomain Status = "nxdomain"
+	// StatusServFail means no nameserver could resolve the name, common for registered domains with lame delegations
+	StatusServFail Status = "servfail"
+)
+
+// answer is a status along with how long it can be cached for, 0 if it can't be
+type answer struct {
+	status Status
+	ttl    time.Duration
+}
+
+// Taken reports whether the status is proof the domain is registered
+func (s Status) Taken() bool {
+	return s == StatusDelegated || s == StatusExists || s == StatusReferral
+}
+
+// Mode decides who lookups are sent to
+type Mode string
+
+const (
+	// ModeRecursive asks the configured nameservers to resolve names on our behalf
+	ModeRecursive Mode = "recursive"
+	// ModeAuthoritative asks the parent zone's own nameservers (eg the .net gTLD servers) for a delegation directly,
+	// the configured nameservers are only used to discover them, and only over plain DNS since that's all they speak
+	ModeAuthoritative Mode = "authoritative"
 )
 
 type Resolver struct {
 	Timeout time.Duration
+
+	nameservers []string // host:port or url for https, never empty
+	strategy    Strategy
+	transport   Transport
+	mode        Mode
+	next        atomic.Uint64
+
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+	httpMethod string
+
+	cache *Cache
+
+	authoritativePort string
+	authoritativeIPv6 bool
+	zonesMu           sync.Mutex
+	zones             map[string]*zoneServers // authoritative nameservers by zone, only used in authoritative mode
 }
 
 type Config struct {
 	Timeout time.Duration
+
+	// Nameservers are addresses like "1.1.1.1", "[2606:4700::1111]:53" or "127.0.0.1:5353" (port defaults to 53, or
+	// 853 over tls), or URLs like "https://cloudflare-dns.com/dns-query" over https. If empty the nameservers from
+	// /etc/resolv.conf are used, which https doesn't support.
+	Nameservers []string
+	Strategy    Strategy  // defaults to round robin
+	Transport   Transport // defaults to udp
+	Mode        Mode      // defaults to recursive
+
+	// AuthoritativePort is the port authoritative nameservers are asked on in authoritative mode, defaults to 53
+	AuthoritativePort string
+	// AuthoritativeIPv6 asks authoritative nameservers over ipv6 as well as ipv4, off by default since ipv6
+	// connectivity can't be assumed. ipv4 addresses are still tried first.
+	AuthoritativeIPv6 bool
+
+	// TLSConfig is used over tls and https, eg to trust a private CA. The server name defaults to the nameserver's host.
+	TLSConfig *tls.Config
+	// HTTPClient is used over https, defaults to a client using TLSConfig
+	HTTPClient *http.Client
+	// HTTPMethod is how queries are sent over https, POST (the default) or GET which plays better with http caches
+	HTTPMethod string
+
+	// Cache holds answers for as long as their TTLs allow, nil disables caching
+	Cache *Cache
 }
 
-func New(cfg Config) *Resolver {
+func New(cfg Config) (*Resolver, error) {
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = StrategyRoundRobin
+	}
+	transport := cfg.Transport
+	if transport == "" {
+		transport = TransportUDP
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeRecursive
+	}
+
+	if strategy != StrategyRoundRobin && strategy != StrategyFailover {
+		return nil, fmt.Errorf("%w: %q", DNSUnknownStrategyError, strategy)
+	}
+	if mode != ModeRecursive && mode != ModeAuthoritative {
+		return nil, fmt.Errorf("%w: %q", DNSUnknownModeError, mode)
+	}
+	authoritativePort := cfg.AuthoritativePort
+	if authoritativePort == "" {
+		authoritativePort = defaultPorts[TransportUDP]
+	}
+	if _, ok := defaultPorts[transport]; !ok && transport != TransportHTTPS {
+		return nil, fmt.Errorf("%w: %q", DNSUnknownTransportError, transport)
+	}
+	httpMethod := strings.ToUpper(cfg.HTTPMethod)
+	if httpMethod == "" {
+		httpMethod = http.MethodPost
+	}
+	if httpMethod != http.MethodGet && httpMethod != http.MethodPost {
+		return nil, fmt.Errorf("%w: %q", DNSHTTPMethodError, cfg.HTTPMethod)
+	}
+
+	nameservers := make([]string, 0, len(cfg.Nameservers))
+	for _, ns := range cfg.Nameservers {
+		if transport == TransportHTTPS {
+			u, err := url.Parse(ns)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return nil, fmt.Errorf("%w: %q isn't an https url", DNSNameserverError, ns)
+			}
+			nameservers = append(nameservers, ns)
+			continue
+		}
+
+		nameservers = append(nameservers, withDefaultPort(ns, defaultPorts[transport]))
+	}
+	if len(nameservers) == 0 {
+		if transport == TransportHTTPS {
+			return nil, DNSNoNameserversError
+		}
+		for _, ns := range systemNameservers() {
+			// resolv.conf only lists hosts, the port is whatever the transport uses
+			host, _, _ := net.SplitHostPort(ns)
+			nameservers = append(nameservers, withDefaultPort(host, defaultPorts[transport]))
+		}
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   cfg.TLSConfig,
+				ForceAttemptHTTP2: true,
+			},
+		}
+	}
+
 	return &Resolver{
 		Timeout: cfg.Timeout,
+
+		nameservers: nameservers,
+		strategy:    strategy,
+		transport:   transport,
+		mode:        mode,
+
+		tlsConfig:  cfg.TLSConfig,
+		httpClient: httpClient,
+		httpMethod: httpMethod,
+
+		cache: cfg.Cache,
+
+		authoritativePort: authoritativePort,
+		authoritativeIPv6: cfg.AuthoritativeIPv6,
+		zones:             make(map[string]*zoneServers),
+	}, nil
+}
+
+func withDefaultPort(address string, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
 	}
+	return net.JoinHostPort(address, port)
 }
 
-/** Check checks if a domain name is taken using DNS lookup. Returns true if taken, false if available, and error if any
- * other error occurs.
+// order returns the indexes of n nameservers in the order they should be tried for the next lookup
+func (r *Resolver) order(n int) []int {
+	start := 0
+	if r.strategy == StrategyRoundRobin {
+		start = int(r.next.Add(1)-1) % n
+	}
+
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = (start + i) % n
+	}
+	return indexes
+}
+
+/** Check checks if a domain name is taken using DNS. Returns true if taken, false if available, and error if the
+ * nameservers couldn't be reached or refused to answer.
  *
- * NOTE: This method may produce false negatives for domains that exist but have no DNS records so it is recommended to
- * use RDAP check or another method as a secondary check.
+ * NOTE: SERVFAIL is reported as not taken since it's inconclusive rather than an error, and an unregistered name never
+ * has records either way, so it is recommended to use RDAP check or another method as a secondary check when false.
  */
 func (r *Resolver) Check(ctx context.Context, domain string) (bool, error) {
+	status, err := r.Lookup(ctx, domain)
+	if err != nil {
+		return false, err
+	}
+	return status.Taken(), nil
+}
+
+/** Lookup asks for the NS records of a domain, falling back to its SOA record if the name exists without any. In
+ * authoritative mode the parent zone's nameservers are asked instead, which answer with a referral or NXDOMAIN. A
+ * nameserver which can't be reached, refuses the query or returns SERVFAIL is skipped for the next one, the error
+ * returned when all of them fail is a *net.DNSError. Answers are cached for as long as their TTL allows when a cache
+ * is configured.
+ */
+func (r *Resolver) Lookup(ctx context.Context, domain string) (Status, error) {
+	key := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if r.cache != nil {
+		if status, ok := r.cache.Get(key, time.Now()); ok {
+			return status, nil
+		}
+	}
+
 	// optional per-call bound if caller didn't set one
 	if r.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
 		defer cancel()
 	}
-	// net.Resolver has Dial context options but for simplicity we use LookupHost (for now)
-	_, err := net.DefaultResolver.LookupHost(ctx, domain)
+
+	var (
+		a   answer
+		err error
+	)
+	if r.mode == ModeAuthoritative {
+		a, err = r.lookupAuthoritative(ctx, domain)
+	} else {
+		a, err = r.lookupRecursive(ctx, domain)
+	}
 	if err != nil {
-		// treat "no such host" as available though the domain is available
-		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.Err == "no such host" {
-			return false, nil
+		return "", err
+	}
+
+	if r.cache != nil && a.ttl > 0 {
+		r.cache.Put(key, a.status, time.Now().Add(a.ttl))
+	}
+	return a.status, nil
+}
+
+func (r *Resolver) lookupRecursive(ctx context.Context, domain string) (answer, error) {
+	var (
+		servFail bool
+		lastErr  error
+	)
+	for _, i := range r.order(len(r.nameservers)) {
+		a, err := r.lookup(ctx, r.nameservers[i], domain)
+		switch {
+		case err != nil:
+			lastErr = err
+		case a.status == StatusServFail:
+			servFail = true
+		default:
+			return a, nil
+		}
+
+		// no point asking anyone else if we ran out of time
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// an answer, even an unhelpful one, beats not hearing back at all
+	if servFail {
+		return answer{status: StatusServFail}, nil
+	}
+	return answer{}, lastErr
+}
+
+func (r *Resolver) lookup(ctx context.Context, server string, domain string) (answer, error) {
+	resp, err := r.query(ctx, r.transport, server, domain, dnsmessage.TypeNS, true)
+	if err != nil {
+		return answer{}, err
+	}
+	a, ok := statusOf(resp, domain, dnsmessage.TypeNS)
+	if ok {
+		return a, nil
+	}
+
+	// NODATA, the name exists but isn't delegated so see if it's a zone apex anyway
+	resp, err = r.query(ctx, r.transport, server, domain, dnsmessage.TypeSOA, true)
+	if err != nil {
+		return answer{}, err
+	}
+	if a, ok := statusOf(resp, domain, dnsmessage.TypeSOA); ok {
+		return a, nil
+	}
+	return answer{status: StatusExists, ttl: negativeTTL(resp)}, nil
+}
+
+func (r *Resolver) query(
+	ctx context.Context,
+	transport Transport,
+	server string,
+	domain string,
+	qtype dnsmessage.Type,
+	recursive bool,
+) (dnsmessage.Message, error) {
+	query, err := newQuery(domain, qtype, recursive)
+	if err != nil {
+		return dnsmessage.Message{}, &net.DNSError{Err: err.Error(), Name: domain, UnwrapErr: err}
+	}
+
+	resp, err := r.exchange(ctx, transport, server, query)
+	if err != nil {
+		var netErr net.Error
+		timeout := errors.As(err, &netErr) && netErr.Timeout()
+		return dnsmessage.Message{}, &net.DNSError{
+			Err:         err.Error(),
+			Name:        domain,
+			Server:      server,
+			IsTimeout:   timeout || errors.Is(err, context.DeadlineExceeded),
+			IsTemporary: true,
+			UnwrapErr:   err,
+		}
+	}
+
+	switch resp.Header.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError, dnsmessage.RCodeServerFailure:
+		return resp, nil
+	default:
+		return dnsmessage.Message{}, rcodeError(domain, server, resp.Header.RCode)
+	}
+}
+
+func rcodeError(domain string, server string, rcode dnsmessage.RCode) *net.DNSError {
+	return &net.DNSError{
+		Err:    "unexpected rcode " + strings.TrimPrefix(rcode.String(), "RCode"),
+		Name:   domain,
+		Server: server,
+		// a refusing server may just be rate limiting us or misconfigured for now, either way the domain is worth
+		// trying again later so it's deferred with a growing cooldown rather than retried every run
+		IsTemporary: true,
+	}
+}
+
+// statusOf interprets a response, returns false if it's NODATA for qtype
+func statusOf(resp dnsmessage.Message, domain string, qtype dnsmessage.Type) (answer, bool) {
+	switch resp.Header.RCode {
+	case dnsmessage.RCodeNameError:
+		return answer{status: StatusNXDomain, ttl: negativeTTL(resp)}, true
+	case dnsmessage.RCodeServerFailure:
+		return answer{status: StatusServFail}, true
+	}
+
+	if len(resp.Answers) == 0 {
+		return answer{}, false
+	}
+	ttl := recordTTL(resp.Answers)
+	for _, rr := range resp.Answers {
+		if rr.Header.Type == qtype && sameName(rr.Header.Name, domain) {
+			return answer{status: StatusDelegated, ttl: ttl}, true
 		}
-		// some other error occurred
-		return false, err
 	}
-	// domain is taken
-	return true, nil
+	// something else answered for the name (eg a CNAME), it exists at least
+	return answer{status: StatusExists, ttl: ttl}, true
 }
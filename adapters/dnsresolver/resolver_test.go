@@ -0,0 +1,468 @@
+package dnsresolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+/** stubServer answers NS and SOA queries over udp and tcp depending on how the name starts:
+ *   taken*     NS records
+ *   nodata*    NOERROR without any records
+ *   nx*        NXDOMAIN with the zone's SOA
+ *   servfail*  SERVFAIL
+ *   refused*   REFUSED
+ * It also stands in for the zones "test." (with glue) and "noglue." (without) in authoritative mode, where taken*
+ * names asked without recursion get a referral.
+ * Every query is logged along with the name of the server which got it.
+ */
+type stubServer struct {
+	name    string
+	udpAddr string
+	tcpAddr string
+	log     *queryLog
+}
+
+type queryLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *queryLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *queryLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+func startStubServer(t *testing.T, name string, log *queryLog) *stubServer {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+
+	s := &stubServer{name: name, udpAddr: pc.LocalAddr().String(), tcpAddr: ln.Addr().String(), log: log}
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.respond(buf[:n]); resp != nil {
+				_, _ = pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					return
+				}
+				resp := s.respond(buf)
+				if resp == nil {
+					return
+				}
+				framed := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+				_, _ = conn.Write(append(framed, resp...))
+			}()
+		}
+	}()
+
+	return s
+}
+
+func (s *stubServer) respond(packed []byte) []byte {
+	var query dnsmessage.Message
+	if err := query.Unpack(packed); err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+	q := query.Questions[0]
+	s.log.add(s.name + " " + q.Name.String())
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.Header.ID, Response: true, RecursionAvailable: true},
+		Questions: query.Questions,
+	}
+	zone := dnsmessage.MustNewName("test.")
+	soa := dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 300},
+		Body: &dnsmessage.SOAResource{
+			NS:     dnsmessage.MustNewName("ns.test."),
+			MBox:   dnsmessage.MustNewName("hostmaster.test."),
+			MinTTL: 60,
+		},
+	}
+
+	ns := dnsmessage.MustNewName("ns1.test.")
+	nsRecord := func(name dnsmessage.Name) dnsmessage.Resource {
+		return dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.NSResource{NS: ns},
+		}
+	}
+	glue := []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Name: ns, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: ns, Type: dnsmessage.TypeAAAA, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}},
+		},
+	}
+
+	switch label := q.Name.String(); {
+	// the zones' own nameservers, which is us, "test." comes with glue and "noglue." has to be looked up
+	case label == "test." && q.Type == dnsmessage.TypeNS:
+		resp.Answers = []dnsmessage.Resource{nsRecord(q.Name)}
+		resp.Additionals = glue
+	case label == "noglue." && q.Type == dnsmessage.TypeNS:
+		resp.Answers = []dnsmessage.Resource{nsRecord(q.Name)}
+	case label == "ns1.test." && q.Type == dnsmessage.TypeA:
+		resp.Answers = glue[:1]
+	// non-recursive queries are asked of the parent zone, which refers us on to the domain's nameservers
+	case strings.HasPrefix(label, "taken") && q.Type == dnsmessage.TypeNS && !query.Header.RecursionDesired:
+		resp.Authorities = []dnsmessage.Resource{nsRecord(q.Name)}
+	case strings.HasPrefix(label, "taken") && q.Type == dnsmessage.TypeNS:
+		resp.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.NSResource{NS: dnsmessage.MustNewName("ns1.example.")},
+		}}
+	case strings.HasPrefix(label, "nx"):
+		resp.Header.RCode = dnsmessage.RCodeNameError
+		resp.Authorities = []dnsmessage.Resource{soa}
+	case strings.HasPrefix(label, "servfail"):
+		resp.Header.RCode = dnsmessage.RCodeServerFailure
+	case strings.HasPrefix(label, "refused"):
+		resp.Header.RCode = dnsmessage.RCodeRefused
+	default:
+		// NODATA
+		resp.Authorities = []dnsmessage.Resource{soa}
+	}
+
+	b, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func (s *stubServer) addr(transport Transport) string {
+	if transport == TransportTCP {
+		return s.tcpAddr
+	}
+	return s.udpAddr
+}
+
+// unreachableAddr returns an address nothing is listening on
+func unreachableAddr(t *testing.T, transport Transport) string {
+	t.Helper()
+
+	if transport == TransportTCP {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		return addr
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+	return addr
+}
+
+func newTestResolver(t *testing.T, transport Transport, strategy Strategy, nameservers ...string) *Resolver {
+	t.Helper()
+
+	r, err := New(Config{
+		Timeout:     2 * time.Second,
+		Nameservers: nameservers,
+		Strategy:    strategy,
+		Transport:   transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLookupStatus(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   Status
+		taken  bool
+	}{
+		{"taken.test", StatusDelegated, true},
+		{"nodata.test", StatusExists, true},
+		{"nx.test", StatusNXDomain, false},
+		{"servfail.test", StatusServFail, false},
+	}
+	for _, transport := range []Transport{TransportUDP, TransportTCP} {
+		s := startStubServer(t, "a", &queryLog{})
+		r := newTestResolver(t, transport, StrategyFailover, s.addr(transport))
+
+		for _, tt := range tests {
+			t.Run(string(transport)+"/"+tt.domain, func(t *testing.T) {
+				got, err := r.Lookup(context.Background(), tt.domain)
+				if err != nil {
+					t.Fatalf("Lookup(%q) error = %v", tt.domain, err)
+				}
+				if got != tt.want {
+					t.Errorf("Lookup(%q) = %q, want %q", tt.domain, got, tt.want)
+				}
+				if got.Taken() != tt.taken {
+					t.Errorf("Lookup(%q).Taken() = %v, want %v", tt.domain, got.Taken(), tt.taken)
+				}
+			})
+		}
+	}
+}
+
+func TestLookupFailover(t *testing.T) {
+	for _, transport := range []Transport{TransportUDP, TransportTCP} {
+		t.Run(string(transport), func(t *testing.T) {
+			log := &queryLog{}
+			s := startStubServer(t, "good", log)
+			r := newTestResolver(t, transport, StrategyFailover, unreachableAddr(t, transport), s.addr(transport))
+
+			for _, domain := range []string{"taken1.test", "taken2.test"} {
+				got, err := r.Lookup(context.Background(), domain)
+				if err != nil {
+					t.Fatalf("Lookup(%q) error = %v", domain, err)
+				}
+				if got != StatusDelegated {
+					t.Errorf("Lookup(%q) = %q, want %q", domain, got, StatusDelegated)
+				}
+			}
+
+			want := []string{"good taken1.test.", "good taken2.test."}
+			if got := log.get(); !slices.Equal(got, want) {
+				t.Errorf("queries = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLookupAllUnreachable(t *testing.T) {
+	r := newTestResolver(t, TransportTCP, StrategyFailover, unreachableAddr(t, TransportTCP), unreachableAddr(t, TransportTCP))
+
+	_, err := r.Lookup(context.Background(), "taken.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("Lookup() error = %v, want a *net.DNSError", err)
+	}
+	if !dnsErr.IsTemporary {
+		t.Errorf("Lookup() error isn't temporary")
+	}
+}
+
+func TestLookupRoundRobin(t *testing.T) {
+	log := &queryLog{}
+	a := startStubServer(t, "a", log)
+	b := startStubServer(t, "b", log)
+	c := startStubServer(t, "c", log)
+	r := newTestResolver(t, TransportUDP, StrategyRoundRobin, a.udpAddr, b.udpAddr, c.udpAddr)
+
+	for _, domain := range []string{"taken1.test", "taken2.test", "taken3.test", "taken4.test"} {
+		if _, err := r.Lookup(context.Background(), domain); err != nil {
+			t.Fatalf("Lookup(%q) error = %v", domain, err)
+		}
+	}
+
+	want := []string{"a taken1.test.", "b taken2.test.", "c taken3.test.", "a taken4.test."}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("queries = %v, want %v", got, want)
+	}
+}
+
+func TestLookupServFailMovesOn(t *testing.T) {
+	log := &queryLog{}
+	a := startStubServer(t, "a", log)
+	b := startStubServer(t, "b", log)
+	r := newTestResolver(t, TransportUDP, StrategyRoundRobin, a.udpAddr, b.udpAddr)
+
+	// every server is asked before giving up on a SERVFAIL
+	got, err := r.Lookup(context.Background(), "servfail.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != StatusServFail {
+		t.Errorf("Lookup() = %q, want %q", got, StatusServFail)
+	}
+
+	want := []string{"a servfail.test.", "b servfail.test."}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("queries = %v, want %v", got, want)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want error
+	}{
+		{name: "unknown strategy", cfg: Config{Strategy: "failvoer"}, want: DNSUnknownStrategyError},
+		{name: "unknown transport", cfg: Config{Transport: "quic"}, want: DNSUnknownTransportError},
+		{name: "unknown mode", cfg: Config{Mode: "authorative"}, want: DNSUnknownModeError},
+		{name: "https without nameservers", cfg: Config{Transport: TransportHTTPS}, want: DNSNoNameserversError},
+		{name: "https nameserver isn't a url", cfg: Config{Transport: TransportHTTPS, Nameservers: []string{"1.1.1.1"}}, want: DNSNameserverError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); !errors.Is(err, tt.want) {
+				t.Errorf("New() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupRefusedIsTemporary(t *testing.T) {
+	a, b := startStubServer(t, "a", &queryLog{}), startStubServer(t, "b", &queryLog{})
+	r := newTestResolver(t, TransportUDP, StrategyFailover, a.udpAddr, b.udpAddr)
+
+	_, err := r.Lookup(context.Background(), "refused.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("Lookup() error = %v, want a *net.DNSError", err)
+	}
+	// so the domain is deferred with a cooldown rather than retried every run
+	if !dnsErr.IsTemporary || dnsErr.IsNotFound {
+		t.Errorf("Lookup() error = %+v, want temporary and not not-found", dnsErr)
+	}
+}
+
+// newAuthoritativeResolver asks s both to discover the zones' nameservers and as those nameservers
+func newAuthoritativeResolver(t *testing.T, s *stubServer, ipv6 bool) *Resolver {
+	t.Helper()
+
+	_, port, _ := net.SplitHostPort(s.udpAddr)
+	r, err := New(Config{
+		Timeout:           2 * time.Second,
+		Nameservers:       []string{s.udpAddr},
+		Strategy:          StrategyFailover,
+		Mode:              ModeAuthoritative,
+		AuthoritativePort: port,
+		AuthoritativeIPv6: ipv6,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLookupAuthoritative(t *testing.T) {
+	s := startStubServer(t, "a", &queryLog{})
+	r := newAuthoritativeResolver(t, s, false)
+
+	tests := []struct {
+		domain string
+		want   Status
+	}{
+		{"taken.test", StatusReferral},
+		{"nx.test", StatusNXDomain},
+		{"servfail.test", StatusServFail},
+		{"nodata.test", StatusExists},
+		// the zone's nameserver comes without glue so its address is looked up
+		{"taken.noglue", StatusReferral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := r.Lookup(context.Background(), tt.domain)
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.domain, err)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupAuthoritativeCachesZoneServers(t *testing.T) {
+	log := &queryLog{}
+	s := startStubServer(t, "a", log)
+	r := newAuthoritativeResolver(t, s, false)
+
+	for _, domain := range []string{"taken.test", "nx1.test", "nx2.test"} {
+		if _, err := r.Lookup(context.Background(), domain); err != nil {
+			t.Fatalf("Lookup(%q) error = %v", domain, err)
+		}
+	}
+
+	discoveries := 0
+	for _, entry := range log.get() {
+		if entry == "a test." {
+			discoveries++
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("zone servers were discovered %d times, want 1: %v", discoveries, log.get())
+	}
+}
+
+func TestZoneServersIPv6(t *testing.T) {
+	s := startStubServer(t, "a", &queryLog{})
+	_, port, _ := net.SplitHostPort(s.udpAddr)
+
+	tests := []struct {
+		ipv6 bool
+		want []string
+	}{
+		{ipv6: false, want: []string{net.JoinHostPort("127.0.0.1", port)}},
+		// ipv4 first so ipv6 is only relied on if it fails
+		{ipv6: true, want: []string{net.JoinHostPort("127.0.0.1", port), net.JoinHostPort("::1", port)}},
+	}
+	for _, tt := range tests {
+		r := newAuthoritativeResolver(t, s, tt.ipv6)
+		got, err := r.zoneServers(context.Background(), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("zoneServers(ipv6=%v) = %v, want %v", tt.ipv6, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package dnsresolver
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+const resolvConfPath = "/etc/resolv.conf"
+
+// used when resolv.conf is missing or lists no nameservers, same as the go and glibc resolvers
+var defaultNameservers = []string{"127.0.0.1:53", "[::1]:53"}
+
+// systemNameservers reads the nameservers configured in resolv.conf
+func systemNameservers() []string {
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return defaultNameservers
+	}
+	defer f.Close()
+
+	var nameservers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+
+		f := strings.Fields(line)
+		if len(f) < 2 || f[0] != "nameserver" {
+			continue
+		}
+		// netip rather than net so link local addresses with a zone, eg fe80::1%eth0, are kept
+		addr, err := netip.ParseAddr(f[1])
+		if err != nil {
+			continue
+		}
+		nameservers = append(nameservers, netip.AddrPortFrom(addr, 53).String())
+	}
+
+	if len(nameservers) == 0 {
+		return defaultNameservers
+	}
+	return nameservers
+}
@@ -0,0 +1,418 @@
+package eppclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khinshankhan/nomex/platform/netx"
+)
+
+const (
+	eppPort = "700"
+	// frames carry their length in a 4 byte header which counts itself, see RFC 5734
+	headerSize = 4
+
+	// how long single checks wait for others to share a batch with
+	defaultBatchDelay = 50 * time.Millisecond
+)
+
+var (
+	EPPMissingCredentialsError = errors.New("eppclient: client id and password are required")
+	EPPFrameSizeError          = errors.New("eppclient: frame size out of range")
+	EPPUnexpectedResponseError = errors.New("eppclient: unexpected response")
+	EPPClosedError             = errors.New("eppclient: client closed")
+)
+
+type (
+	Client struct {
+		address    string
+		tlsConfig  *tls.Config
+		clientID   string
+		password   string
+		timeout    time.Duration
+		batchSize  int
+		batchDelay time.Duration
+
+		// one command at a time per session, EPP doesn't pipeline
+		mu     sync.Mutex
+		conn   net.Conn
+		closed bool
+		trid   atomic.Uint64
+
+		queueMu sync.Mutex
+		queue   []*pendingCheck
+	}
+
+	Config struct {
+		// Address of the EPP server, eg "epp.example.net", the port defaults to 700
+		Address string
+		// TLSConfig eg for client certificates which registries usually require, the server name defaults to the
+		// address' host
+		TLSConfig *tls.Config
+		ClientID  string
+		Password  string
+
+		Timeout    time.Duration // per command, defaults to 30s
+		BatchSize  int           // names per domain:check, defaults to 10
+		BatchDelay time.Duration // how long Check waits to batch with concurrent calls, defaults to 50ms
+	}
+
+	// CheckResult is the server's answer for one name of a domain:check
+	CheckResult struct {
+		Domain    string
+		Available bool
+		Reason    string // why the domain isn't available, eg "In use" or "Reserved", if the server said
+	}
+
+	Greeting struct {
+		ServerID string
+		Versions []string
+		ObjURIs  []string
+	}
+
+	pendingCheck struct {
+		domain string
+		done   chan checkOutcome
+	}
+
+	checkOutcome struct {
+		result CheckResult
+		err    error
+	}
+)
+
+func New(cfg Config) (*Client, error) {
+	if cfg.ClientID == "" || cfg.Password == "" {
+		return nil, EPPMissingCredentialsError
+	}
+
+	address := cfg.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, eppPort)
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.TLSConfig != nil {
+		tlsConfig = cfg.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(address)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	batchDelay := cfg.BatchDelay
+	if batchDelay <= 0 {
+		batchDelay = defaultBatchDelay
+	}
+
+	return &Client{
+		address:    address,
+		tlsConfig:  tlsConfig,
+		clientID:   cfg.ClientID,
+		password:   cfg.Password,
+		timeout:    timeout,
+		batchSize:  batchSize,
+		batchDelay: batchDelay,
+	}, nil
+}
+
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)))
+	copy(frame[headerSize:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size < headerSize || size > netx.MaxResponseSize {
+		return nil, fmt.Errorf("%w: %d", EPPFrameSizeError, size)
+	}
+	payload := make([]byte, size-headerSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (c *Client) nextTRID() string {
+	return fmt.Sprintf("nomex-%d-%d", time.Now().Unix(), c.trid.Add(1))
+}
+
+// roundTrip sends req over the session and reads the response, c.mu must be held
+func (c *Client) roundTrip(ctx context.Context, req *eppRequest) (*eppResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	unbind, err := netx.BindContext(ctx, c.conn, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unbind()
+
+	if req != nil {
+		payload, err := xml.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFrame(c.conn, append([]byte(xml.Header), payload...)); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := readFrame(c.conn)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	var resp eppResponse
+	if err := xml.Unmarshal(payload, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// command sends cmd and returns the response, failing on any error result. c.mu must be held.
+func (c *Client) command(ctx context.Context, cmd *command) (*response, error) {
+	cmd.ClTRID = c.nextTRID()
+	resp, err := c.roundTrip(ctx, &eppRequest{Command: cmd})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Response == nil {
+		return nil, EPPUnexpectedResponseError
+	}
+	return resp.Response, resp.Response.err()
+}
+
+// connect opens a session and logs in if there isn't one already, c.mu must be held
+func (c *Client) connect(ctx context.Context) error {
+	if c.closed {
+		return EPPClosedError
+	}
+	if c.conn != nil {
+		return nil
+	}
+
+	// the dial, handshake and greeting share one timeout, otherwise a server which accepts but never says anything
+	// holds the session (and everyone waiting on it) for as long as ctx allows, forever for flush's batches
+	connectCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	dialer := tls.Dialer{Config: c.tlsConfig}
+	conn, err := dialer.DialContext(connectCtx, "tcp", c.address)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+
+	// the server greets us as soon as we connect
+	resp, err := c.roundTrip(connectCtx, nil)
+	if err == nil && resp.Greeting == nil {
+		err = EPPUnexpectedResponseError
+	}
+	if err == nil {
+		_, err = c.command(ctx, &command{
+			Login: &login{
+				ClID:    c.clientID,
+				PW:      c.password,
+				Version: "1.0",
+				Lang:    "en",
+				ObjURIs: []string{domainNS},
+			},
+		})
+	}
+	if err != nil {
+		c.disconnect()
+		return err
+	}
+	return nil
+}
+
+// disconnect drops the session without logging out, c.mu must be held
+func (c *Client) disconnect() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// withSession runs fn over a logged in session, reconnecting once if the session turns out to be dead
+func (c *Client) withSession(ctx context.Context, fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = c.connect(ctx); err != nil {
+			return err
+		}
+
+		err = fn()
+		var re *ResultError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &re) && !re.SessionClosing():
+			// the session is fine, the command just failed
+			return err
+		case ctx.Err() != nil:
+			c.disconnect()
+			return err
+		}
+		// broken or closing session, a fresh one may do better
+		c.disconnect()
+	}
+	return err
+}
+
+// Hello asks the server for its greeting, which also keeps an idle session alive
+func (c *Client) Hello(ctx context.Context) (Greeting, error) {
+	var greeting Greeting
+	err := c.withSession(ctx, func() error {
+		resp, err := c.roundTrip(ctx, &eppRequest{Hello: &struct{}{}})
+		if err != nil {
+			return err
+		}
+		if resp.Greeting == nil {
+			return EPPUnexpectedResponseError
+		}
+		greeting = Greeting(*resp.Greeting)
+		return nil
+	})
+	return greeting, err
+}
+
+// CheckDomains sends a domain:check for domains, split into batches of the configured size. Results are in the same
+// order as domains.
+func (c *Client) CheckDomains(ctx context.Context, domains []string) ([]CheckResult, error) {
+	results := make([]CheckResult, 0, len(domains))
+	for start := 0; start < len(domains); start += c.batchSize {
+		batch := domains[start:min(start+c.batchSize, len(domains))]
+
+		err := c.withSession(ctx, func() error {
+			resp, err := c.command(ctx, &command{Check: &check{Domain: domainCheck{Names: batch}}})
+			if err != nil {
+				return err
+			}
+			if resp.ChkData == nil {
+				return EPPUnexpectedResponseError
+			}
+
+			byName := make(map[string]CheckResult, len(resp.ChkData.CDs))
+			for _, cd := range resp.ChkData.CDs {
+				name := strings.ToLower(strings.TrimSpace(cd.Name.Value))
+				byName[name] = CheckResult{
+					Domain:    name,
+					Available: cd.Name.Avail == "1" || cd.Name.Avail == "true",
+					Reason:    strings.TrimSpace(cd.Reason),
+				}
+			}
+			for _, d := range batch {
+				result, ok := byName[strings.ToLower(d)]
+				if !ok {
+					return fmt.Errorf("%w: no result for %s", EPPUnexpectedResponseError, d)
+				}
+				results = append(results, result)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+/** Check checks if a domain name is available using EPP domain:check, the registry's own answer. Concurrent calls are
+ * batched into a single domain:check, so a call may wait up to the batch delay for others to join it.
+ */
+func (c *Client) Check(ctx context.Context, domainName string) (CheckResult, error) {
+	p := &pendingCheck{domain: domainName, done: make(chan checkOutcome, 1)}
+
+	c.queueMu.Lock()
+	c.queue = append(c.queue, p)
+	switch n := len(c.queue); {
+	case n >= c.batchSize:
+		go c.flush()
+	case n == 1:
+		// the first of a batch starts the clock for everyone else
+		time.AfterFunc(c.batchDelay, c.flush)
+	}
+	c.queueMu.Unlock()
+
+	select {
+	case outcome := <-p.done:
+		return outcome.result, outcome.err
+	case <-ctx.Done():
+		return CheckResult{}, ctx.Err()
+	}
+}
+
+// flush checks everything queued by Check, nothing happens if another flush got there first
+func (c *Client) flush() {
+	c.queueMu.Lock()
+	queue := c.queue
+	c.queue = nil
+	c.queueMu.Unlock()
+	if len(queue) == 0 {
+		return
+	}
+
+	domains := make([]string, len(queue))
+	for i, p := range queue {
+		domains[i] = p.domain
+	}
+
+	// the batch is shared so it isn't bound by any single caller's ctx, callers stop waiting on their own. Every step
+	// of it is bound by c.timeout instead, from connecting through to each command.
+	results, err := c.CheckDomains(context.Background(), domains)
+	for i, p := range queue {
+		if err != nil {
+			p.done <- checkOutcome{err: err}
+			continue
+		}
+		p.done <- checkOutcome{result: results[i]}
+	}
+}
+
+// Close logs out and ends the session
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	defer c.disconnect()
+
+	_, err := c.command(ctx, &command{Logout: &struct{}{}})
+	return err
+}
@@ -0,0 +1,453 @@
+package eppclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubRequest is what the stub understands of the requests the client sends
+type stubRequest struct {
+	XMLName xml.Name  `xml:"epp"`
+	Hello   *struct{} `xml:"hello"`
+	Command *struct {
+		Login *struct {
+			ClID string `xml:"clID"`
+			PW   string `xml:"pw"`
+		} `xml:"login"`
+		Logout *struct{} `xml:"logout"`
+		Check  *struct {
+			Domain struct {
+				Names []string `xml:"name"`
+			} `xml:"urn:ietf:params:xml:ns:domain-1.0 check"`
+		} `xml:"check"`
+		ClTRID string `xml:"clTRID"`
+	} `xml:"command"`
+}
+
+/** stubServer is an EPP server over TLS which logs every command it gets. Checked names starting with "free" are
+ * available, "premium" and "reserved" ones are held back by the registry with that reason, and anything else is in use.
+ */
+type stubServer struct {
+	t       *testing.T
+	ln      net.Listener
+	address string
+	roots   *tls.Config
+
+	mu     sync.Mutex
+	events []string
+	// the next check is answered by dropping the connection, or by closing the session with a 2500 result
+	dropNextCheck    bool
+	closingNextCheck bool
+	// connections are accepted but never greeted
+	silent bool
+}
+
+func startStubServer(t *testing.T) *stubServer {
+	t.Helper()
+
+	// borrow httptest's certificate, which is valid for 127.0.0.1, along with a client config trusting it
+	certSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(certSrv.Close)
+	clientConfig := certSrv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certSrv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &stubServer{t: t, ln: ln, address: ln.Addr().String(), roots: &tls.Config{RootCAs: clientConfig.RootCAs}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *stubServer) log(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *stubServer) getEvents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.events)
+}
+
+func (s *stubServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.log("connect")
+	s.mu.Lock()
+	silent := s.silent
+	s.mu.Unlock()
+	if silent {
+		// reading is enough for the handshake to go through
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+
+	if writeFrame(conn, []byte(stubGreeting)) != nil {
+		return
+	}
+
+	for {
+		payload, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		var req stubRequest
+		if err := xml.Unmarshal(payload, &req); err != nil {
+			s.t.Errorf("stub couldn't parse request: %v\n%s", err, payload)
+			return
+		}
+
+		var resp string
+		switch cmd := req.Command; {
+		case req.Hello != nil:
+			s.log("hello")
+			resp = stubGreeting
+		case cmd != nil && cmd.Login != nil:
+			s.log("login " + cmd.Login.ClID)
+			code := 1000
+			if cmd.Login.PW != "secret" {
+				code = 2200
+			}
+			resp = stubResult(code, cmd.ClTRID, "")
+		case cmd != nil && cmd.Logout != nil:
+			s.log("logout")
+			_ = writeFrame(conn, []byte(stubResult(1500, cmd.ClTRID, "")))
+			return
+		case cmd != nil && cmd.Check != nil:
+			names := cmd.Check.Domain.Names
+			s.mu.Lock()
+			drop, closing := s.dropNextCheck, s.closingNextCheck
+			s.dropNextCheck, s.closingNextCheck = false, false
+			s.mu.Unlock()
+			if drop {
+				s.log("drop")
+				return
+			}
+			if closing {
+				s.log("closing")
+				_ = writeFrame(conn, []byte(stubResult(2500, cmd.ClTRID, "")))
+				return
+			}
+
+			s.log("check " + strings.Join(names, ","))
+			resp = stubResult(1000, cmd.ClTRID, stubChkData(names))
+		default:
+			s.t.Errorf("stub got an unexpected request:\n%s", payload)
+			return
+		}
+
+		if writeFrame(conn, []byte(resp)) != nil {
+			return
+		}
+	}
+}
+
+const stubGreeting = xml.Header + `<epp xmlns="urn:ietf:params:xml:ns:epp-1.0"><greeting>
+<svID>Stub EPP</svID><svDate>2026-01-01T00:00:00.0Z</svDate>
+<svcMenu><version>1.0</version><lang>en</lang><objURI>urn:ietf:params:xml:ns:domain-1.0</objURI></svcMenu>
+</greeting></epp>`
+
+func stubResult(code int, clTRID string, resData string) string {
+	return fmt.Sprintf(xml.Header+`<epp xmlns="urn:ietf:params:xml:ns:epp-1.0"><response>
+<result code="%d"><msg>stub</msg></result>%s
+<trID><clTRID>%s</clTRID><svTRID>stub-1</svTRID></trID>
+</response></epp>`, code, resData, clTRID)
+}
+
+func stubChkData(names []string) string {
+	var b strings.Builder
+	b.WriteString(`<resData><domain:chkData xmlns:domain="urn:ietf:params:xml:ns:domain-1.0">`)
+	for _, name := range names {
+		avail, reason := "0", "In use"
+		switch lower := strings.ToLower(name); {
+		case strings.HasPrefix(lower, "freetrue"):
+			avail, reason = "true", ""
+		case strings.HasPrefix(lower, "free"):
+			avail, reason = "1", ""
+		case strings.HasPrefix(lower, "premium"):
+			reason = "Premium name"
+		case strings.HasPrefix(lower, "reserved"):
+			reason = "Reserved by registry"
+		}
+		// servers may echo names in a different case than they were asked
+		fmt.Fprintf(&b, `<domain:cd><domain:name avail="%s">%s</domain:name>`, avail, strings.ToUpper(name))
+		if reason != "" {
+			fmt.Fprintf(&b, `<domain:reason> %s </domain:reason>`, reason)
+		}
+		b.WriteString(`</domain:cd>`)
+	}
+	b.WriteString(`</domain:chkData></resData>`)
+	return b.String()
+}
+
+func newTestClient(t *testing.T, s *stubServer, cfg Config) *Client {
+	t.Helper()
+
+	cfg.Address = s.address
+	cfg.TLSConfig = s.roots
+	if cfg.ClientID == "" {
+		cfg.ClientID, cfg.Password = "registrar", "secret"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, []byte("<epp/>")); err != nil {
+		t.Fatal(err)
+	}
+	// the length counts the 4 byte header as well as the payload
+	if got := binary.BigEndian.Uint32(buf.Bytes()); got != 4+6 {
+		t.Errorf("frame length = %d, want %d", got, 4+6)
+	}
+
+	payload, err := readFrame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != "<epp/>" {
+		t.Errorf("readFrame() = %q, want %q", payload, "<epp/>")
+	}
+
+	for _, size := range []uint32{0, 3, 1<<20 + 1} {
+		frame := binary.BigEndian.AppendUint32(nil, size)
+		if _, err := readFrame(bytes.NewReader(frame)); !errors.Is(err, EPPFrameSizeError) {
+			t.Errorf("readFrame() of a %d byte frame error = %v, want %v", size, err, EPPFrameSizeError)
+		}
+	}
+}
+
+func TestGreetingAndLogin(t *testing.T) {
+	s := startStubServer(t)
+	c := newTestClient(t, s, Config{})
+
+	greeting, err := c.Hello(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if greeting.ServerID != "Stub EPP" || !slices.Equal(greeting.Versions, []string{"1.0"}) {
+		t.Errorf("Hello() = %+v", greeting)
+	}
+
+	want := []string{"connect", "login registrar", "hello"}
+	if got := s.getEvents(); !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestLoginFailure(t *testing.T) {
+	s := startStubServer(t)
+	c := newTestClient(t, s, Config{ClientID: "registrar", Password: "wrong"})
+
+	_, err := c.CheckDomains(context.Background(), []string{"free.test"})
+	var re *ResultError
+	if !errors.As(err, &re) || re.Code != 2200 {
+		t.Errorf("CheckDomains() error = %v, want result 2200", err)
+	}
+}
+
+func TestCheckDomains(t *testing.T) {
+	s := startStubServer(t)
+	c := newTestClient(t, s, Config{BatchSize: 3})
+
+	domains := []string{"free1.test", "taken.test", "premium.test", "reserved.test", "freetrue.test", "Free2.test", "other.test"}
+	got, err := c.CheckDomains(context.Background(), domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CheckResult{
+		{Domain: "free1.test", Available: true},
+		{Domain: "taken.test", Reason: "In use"},
+		{Domain: "premium.test", Reason: "Premium name"},
+		{Domain: "reserved.test", Reason: "Reserved by registry"},
+		{Domain: "freetrue.test", Available: true},
+		{Domain: "free2.test", Available: true},
+		{Domain: "other.test", Reason: "In use"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("CheckDomains() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// batches of 3 over a single session
+	wantEvents := []string{
+		"connect",
+		"login registrar",
+		"check free1.test,taken.test,premium.test",
+		"check reserved.test,freetrue.test,Free2.test",
+		"check other.test",
+	}
+	if got := s.getEvents(); !slices.Equal(got, wantEvents) {
+		t.Errorf("events = %v, want %v", got, wantEvents)
+	}
+}
+
+func TestCheckBatchesConcurrentCalls(t *testing.T) {
+	s := startStubServer(t)
+	c := newTestClient(t, s, Config{BatchSize: 10, BatchDelay: 100 * time.Millisecond})
+
+	domains := []string{"free1.test", "free2.test", "free3.test", "free4.test"}
+	var wg sync.WaitGroup
+	for _, d := range domains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.Check(context.Background(), d)
+			if err != nil || !result.Available || result.Domain != d {
+				t.Errorf("Check(%q) = %+v, %v", d, result, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	checks := 0
+	for _, e := range s.getEvents() {
+		if strings.HasPrefix(e, "check ") {
+			checks++
+			if n := len(strings.Split(strings.TrimPrefix(e, "check "), ",")); n != len(domains) {
+				t.Errorf("%q has %d names, want %d", e, n, len(domains))
+			}
+		}
+	}
+	if checks != 1 {
+		t.Errorf("sent %d checks, want 1", checks)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	tests := []struct {
+		name         string
+		breakSession func(s *stubServer)
+		event        string
+	}{
+		{"dropped connection", func(s *stubServer) { s.dropNextCheck = true }, "drop"},
+		{"session closing", func(s *stubServer) { s.closingNextCheck = true }, "closing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startStubServer(t)
+			c := newTestClient(t, s, Config{})
+
+			if _, err := c.CheckDomains(context.Background(), []string{"free1.test"}); err != nil {
+				t.Fatal(err)
+			}
+			s.mu.Lock()
+			tt.breakSession(s)
+			s.mu.Unlock()
+
+			got, err := c.CheckDomains(context.Background(), []string{"free2.test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !got[0].Available {
+				t.Errorf("CheckDomains() = %+v", got)
+			}
+
+			want := []string{
+				"connect", "login registrar", "check free1.test",
+				tt.event,
+				"connect", "login registrar", "check free2.test",
+			}
+			if got := s.getEvents(); !slices.Equal(got, want) {
+				t.Errorf("events = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := startStubServer(t)
+	c := newTestClient(t, s, Config{})
+
+	if _, err := c.CheckDomains(context.Background(), []string{"free.test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.getEvents(); got[len(got)-1] != "logout" {
+		t.Errorf("events = %v, want a logout last", got)
+	}
+
+	if _, err := c.CheckDomains(context.Background(), []string{"free.test"}); !errors.Is(err, EPPClosedError) {
+		t.Errorf("CheckDomains() after Close error = %v, want %v", err, EPPClosedError)
+	}
+}
+
+func TestConnectTimesOut(t *testing.T) {
+	// a tls server which never greets us, and a tcp one which never even finishes the handshake
+	quiet := startStubServer(t)
+	quiet.mu.Lock()
+	quiet.silent = true
+	quiet.mu.Unlock()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	mute := &stubServer{t: t, address: ln.Addr().String(), roots: quiet.roots}
+
+	for name, s := range map[string]*stubServer{"greeting": quiet, "handshake": mute} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestClient(t, s, Config{Timeout: 200 * time.Millisecond, BatchDelay: time.Millisecond})
+
+			// batched checks don't run under the caller's ctx so only the client's own timeout can end them
+			start := time.Now()
+			_, err := c.Check(context.Background(), "free.test")
+			// either the deadline on ctx or on the connection, whichever is noticed first
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				t.Errorf("Check() error = %v, want a timeout", err)
+			}
+			if took := time.Since(start); took > 2*time.Second {
+				t.Errorf("Check() took %v to give up", took)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package eppclient
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+const (
+	eppNS    = "urn:ietf:params:xml:ns:epp-1.0"
+	domainNS = "urn:ietf:params:xml:ns:domain-1.0"
+)
+
+// result codes we care about, see RFC 5730 section 3
+const (
+	codeSuccess       = 1000
+	codeLogoutSuccess = 1500
+	// 25xx codes mean the server is closing the session
+	codeSessionClosingMin = 2500
+	codeSessionClosingMax = 2502
+	codeSessionLimit      = 2502
+)
+
+type (
+	eppRequest struct {
+		XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:epp-1.0 epp"`
+		Hello   *struct{} `xml:"hello,omitempty"`
+		Command *command  `xml:"command,omitempty"`
+	}
+
+	command struct {
+		Login  *login    `xml:"login,omitempty"`
+		Logout *struct{} `xml:"logout,omitempty"`
+		Check  *check    `xml:"check,omitempty"`
+		ClTRID string    `xml:"clTRID"`
+	}
+
+	login struct {
+		ClID    string   `xml:"clID"`
+		PW      string   `xml:"pw"`
+		Version string   `xml:"options>version"`
+		Lang    string   `xml:"options>lang"`
+		ObjURIs []string `xml:"svcs>objURI"`
+	}
+
+	check struct {
+		Domain domainCheck `xml:"urn:ietf:params:xml:ns:domain-1.0 check"`
+	}
+
+	domainCheck struct {
+		Names []string `xml:"name"`
+	}
+)
+
+type (
+	eppResponse struct {
+		XMLName  xml.Name  `xml:"epp"`
+		Greeting *greeting `xml:"greeting"`
+		Response *response `xml:"response"`
+	}
+
+	greeting struct {
+		ServerID string   `xml:"svID"`
+		Versions []string `xml:"svcMenu>version"`
+		ObjURIs  []string `xml:"svcMenu>objURI"`
+	}
+
+	response struct {
+		Results []result `xml:"result"`
+		ChkData *chkData `xml:"resData>chkData"`
+		SvTRID  string   `xml:"trID>svTRID"`
+	}
+
+	result struct {
+		Code int    `xml:"code,attr"`
+		Msg  string `xml:"msg"`
+	}
+
+	chkData struct {
+		CDs []cd `xml:"cd"`
+	}
+
+	cd struct {
+		Name struct {
+			Avail string `xml:"avail,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"name"`
+		Reason string `xml:"reason"`
+	}
+)
+
+// ResultError is an EPP result code outside of the success range
+type ResultError struct {
+	Code int
+	Msg  string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("eppclient: result %d: %s", e.Code, e.Msg)
+}
+
+// SessionClosing reports whether the server ended the session with the error, eg because of a session limit
+func (e *ResultError) SessionClosing() bool {
+	return e.Code >= codeSessionClosingMin && e.Code <= codeSessionClosingMax
+}
+
+// RateLimited reports whether the error is the server limiting sessions or commands
+func (e *ResultError) RateLimited() bool {
+	return e.Code == codeSessionLimit
+}
+
+// err returns the first failing result of resp as a *ResultError, nil if every result succeeded
+func (resp *response) err() error {
+	if len(resp.Results) == 0 {
+		return &ResultError{Msg: "response without a result"}
+	}
+	for _, r := range resp.Results {
+		if r.Code >= 2000 {
+			return &ResultError{Code: r.Code, Msg: r.Msg}
+		}
+	}
+	return nil
+}
@@ -3,8 +3,12 @@ package rdapclient
 import (
 	"context"
 	"errors"
-	"net"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/openrdap/rdap"
@@ -12,13 +16,67 @@ import (
 	"github.com/openrdap/rdap/bootstrap/cache"
 )
 
+var RDAPBootstrapURLError = errors.New("rdapclient: invalid bootstrap url")
+
+/** StatusError is returned when an RDAP server answers with an unexpected http status, eg 429 Too Many Requests or
+ * 503 Service Unavailable. RetryAfter is how long the server asked us to wait before trying again, 0 if it didn't say.
+ */
+type StatusError struct {
+	Server     string
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rdapclient: %s returned %d, retry after %s: %v", e.Server, e.StatusCode, e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rdapclient: %s returned %d: %v", e.Server, e.StatusCode, e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimited reports whether the server is telling us to slow down
+func (e *StatusError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+/** Client is safe for concurrent use. The openrdap bootstrap client isn't, so every bootstrap lookup goes through
+ * ServerFor which serializes them and remembers the answer per zone, and queries are always sent with their server
+ * already set so rdap.Client never bootstraps on its own.
+ */
 type Client struct {
 	rc *rdap.Client
+	b  *bootstrap.Client
+
+	bootstrapMu sync.Mutex
+	serversMu   sync.RWMutex
+	servers     map[string]cachedServer // keyed by the zone a domain is in, eg "com" for example.com
+}
+
+type cachedServer struct {
+	base    *url.URL
+	expires time.Time
+}
+
+// copy hands out a url of its own to every request, rdap.Request.URL writes to the one it's given
+func (s cachedServer) copy() *url.URL {
+	u := *s.base
+	return &u
 }
 
 type Config struct {
 	UserAgent  string
 	HTTPClient *http.Client // optional, default with timeout if nil
+
+	// BootstrapURL is where the service registry files (dns.json etc) are downloaded from, defaults to IANA's
+	// https://data.iana.org/rdap/. Useful for a mirror or a test server.
+	BootstrapURL string
+	// BootstrapCacheDir is where downloaded registry files are cached, defaults to ~/.openrdap
+	BootstrapCacheDir string
 }
 
 func New(cfg Config) (*Client, error) {
@@ -33,24 +91,45 @@ func New(cfg Config) (*Client, error) {
 	b := &bootstrap.Client{
 		HTTP: httpClient,
 	}
+	if cfg.BootstrapURL != "" {
+		u, err := url.Parse(cfg.BootstrapURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%w: %q", RDAPBootstrapURLError, cfg.BootstrapURL)
+		}
+		b.BaseURL = u
+	}
 
-	// uses ~/.openrdap by default, they say default but it's not configurable :/
+	// uses ~/.openrdap by default, files from anywhere other than IANA are cached under a prefix so they don't mix
 	// https://github.com/openrdap/rdap/blob/master/bootstrap/cache/disk_cache.go
-	b.Cache = cache.NewDiskCache()
+	diskCache := cache.NewDiskCache()
+	if cfg.BootstrapCacheDir != "" {
+		diskCache.Dir = cfg.BootstrapCacheDir
+	}
+	b.Cache = diskCache
 
 	rc := &rdap.Client{
 		HTTP:      httpClient,
 		Bootstrap: b,
 		UserAgent: cfg.UserAgent,
+		// set up front, Do fills it in otherwise which races with concurrent calls
+		Verbose: func(string) {},
 	}
-	return &Client{rc: rc}, nil
+	return &Client{rc: rc, b: b, servers: make(map[string]cachedServer)}, nil
 }
 
-// QueryDomainRaw preserves RDAP problem details instead of collapsing them.
+/** QueryDomainRaw preserves RDAP problem details instead of collapsing them. The query only goes to the server
+ * ServerFor returns, which is the one callers pace themselves against.
+ */
 func (c *Client) QueryDomainRaw(ctx context.Context, domainName string) (*rdap.Response, error) {
+	server, err := c.server(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
 	req := &rdap.Request{
-		Type:  rdap.DomainRequest,
-		Query: domainName,
+		Type:   rdap.DomainRequest,
+		Query:  domainName,
+		Server: server,
 	}
 	req = req.WithContext(ctx)
 
@@ -58,61 +137,164 @@ func (c *Client) QueryDomainRaw(ctx context.Context, domainName string) (*rdap.R
 	return resp, err
 }
 
-/** Check checks if a domain name is taken using RDAP. Returns 200 if taken, 404 if available, and a different code if
- * any other error occurs.
- *
- * NOTE: This method is more reliable than DNS check as it queries the authoritative source for domain registration
- * data, this method is preferred over DNS check however it may be slower due to network latency and RDAP server
- * response times and it can be rate limited by RDAP servers... it's also bad actor to spam RDAP servers with requests.
+/** ServerFor returns the base url of the RDAP server responsible for domainName according to the IANA bootstrap
+ * registry, ie the server Check will ask. The answer is remembered per zone for as long as the registry itself is
+ * cached so this is cheap after the first call for a zone.
  */
-func (c *Client) Check(ctx context.Context, domainName string) (int, error) {
-	_, err := c.QueryDomainRaw(ctx, domainName)
+func (c *Client) ServerFor(ctx context.Context, domainName string) (string, error) {
+	server, err := c.server(ctx, domainName)
+	if err != nil {
+		return "", err
+	}
+	return server.String(), nil
+}
 
-	// registered
-	if err == nil {
-		return 200, nil
+// zoneOf returns the zone a domain is delegated from, ie everything after its first label
+func zoneOf(domainName string) string {
+	domainName = strings.ToLower(strings.TrimSuffix(domainName, "."))
+	if _, zone, ok := strings.Cut(domainName, "."); ok {
+		return zone
 	}
+	return domainName
+}
 
-	// map typed error to codes which indicate availability
-	var ce *rdap.ClientError
-	if errors.As(err, &ce) {
-		switch ce.Type {
-		case rdap.ObjectDoesNotExist:
-			// not found
-			return 404, nil
-
-		// lowkey I don't really know what all these codes mean in practice, but this is my best guess
-		case rdap.InputError:
-			return 400, err
-		case rdap.BootstrapNotSupported:
-			return 501, err
-		case rdap.BootstrapNoMatch, rdap.WrongResponseType, rdap.RDAPServerError:
-			return 502, err
-		case rdap.NoWorkingServers:
-			return 503, err
-		default:
-			return 502, err
+func (c *Client) server(ctx context.Context, domainName string) (*url.URL, error) {
+	zone := zoneOf(domainName)
+	now := time.Now()
+
+	c.serversMu.RLock()
+	cached, ok := c.servers[zone]
+	c.serversMu.RUnlock()
+	if ok && now.Before(cached.expires) {
+		return cached.copy(), nil
+	}
+
+	// one lookup at a time, the bootstrap client isn't safe for concurrent use
+	c.bootstrapMu.Lock()
+	defer c.bootstrapMu.Unlock()
+
+	// someone else may have looked the zone up while we waited
+	c.serversMu.RLock()
+	cached, ok = c.servers[zone]
+	c.serversMu.RUnlock()
+	if ok && now.Before(cached.expires) {
+		return cached.copy(), nil
+	}
+
+	question := &bootstrap.Question{
+		RegistryType: bootstrap.DNS,
+		Query:        domainName,
+	}
+	answer, err := c.b.Lookup(question.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// same error the rdap client gives when it can't find a server
+	if len(answer.URLs) == 0 {
+		return nil, &rdap.ClientError{
+			Type: rdap.BootstrapNoMatch,
+			Text: fmt.Sprintf("No RDAP servers found for '%s'", answer.Query),
 		}
 	}
 
-	// context classification first (to avoid being masked by *url.Error -> net.Error)
-	if errors.Is(err, context.DeadlineExceeded) {
-		return 504, err
+	u := *answer.URLs[0]
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	c.serversMu.Lock()
+	cached = cachedServer{base: &u, expires: now.Add(bootstrap.DefaultCacheTimeout)}
+	c.servers[zone] = cached
+	c.serversMu.Unlock()
+	return cached.copy(), nil
+}
+
+// baseURL strips the query off a request url, ie <base>/domain/<name> -> <base>/
+func baseURL(u string) string {
+	if i := strings.LastIndex(u, "/domain/"); i >= 0 {
+		return u[:i+1]
+	}
+	return u
+}
+
+// serverURL returns the base url of the last RDAP server queried for resp, or "" if none was reached.
+func serverURL(resp *rdap.Response) string {
+	if resp == nil || len(resp.HTTP) == 0 {
+		return ""
+	}
+	return baseURL(resp.HTTP[len(resp.HTTP)-1].URL)
+}
+
+/** parseRetryAfter parses a Retry-After header, which is either a number of seconds or an http date (RFC 9110 section
+ * 10.2.3). Returns 0 if the header is missing, malformed or already in the past.
+ */
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// statusError wraps err with the http status of the last server reached, if it answered with something unexpected
+func statusError(resp *rdap.Response, err error) error {
+	if resp == nil || len(resp.HTTP) == 0 {
+		return err
 	}
-	if errors.Is(err, context.Canceled) {
-		return 499, err
+
+	last := resp.HTTP[len(resp.HTTP)-1]
+	if last.Response == nil || last.Response.StatusCode < 300 {
+		return err
 	}
+	return &StatusError{
+		Server:     baseURL(last.URL),
+		StatusCode: last.Response.StatusCode,
+		RetryAfter: parseRetryAfter(last.Response.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
 
-	// network errors = service unavailable / gateway timeout
-	var ne net.Error
-	if errors.As(err, &ne) {
-		if ne.Timeout() {
-			return 504, err
+/** Lookup returns the registration of a domain name using RDAP, or nil if it isn't registered. The base url of the
+ * RDAP server that was asked is returned as well, if one was reached.
+ */
+func (c *Client) Lookup(ctx context.Context, domainName string) (*Registration, string, error) {
+	resp, err := c.QueryDomainRaw(ctx, domainName)
+	server := serverURL(resp)
+
+	// registered
+	if err == nil {
+		if d, ok := resp.Object.(*rdap.Domain); ok {
+			return parseRegistration(domainName, d), server, nil
 		}
-		// temporary connect/ reset/ dns hiccup etc
-		return 503, err
+		// the server answered with something other than a domain, it's still there so it's taken but that's all we know
+		return &Registration{Domain: domainName}, server, nil
 	}
 
-	// unknown error, treat as upstream error because clearly we didn't cause it (probably)
-	return 502, err
+	// not found
+	var ce *rdap.ClientError
+	if errors.As(err, &ce) && ce.Type == rdap.ObjectDoesNotExist {
+		return nil, server, nil
+	}
+
+	// the raw error is preserved so callers can classify it, along with the http status if there was one
+	return nil, server, statusError(resp, err)
+}
+
+/** Check checks if a domain name is taken using RDAP. Returns true if taken, false if available, and error if any
+ * other error occurs. The base url of the RDAP server that was asked is returned as well, if one was reached.
+ *
+ * NOTE: This method is more reliable than DNS check as it queries the authoritative source for domain registration
+ * data, this method is preferred over DNS check however it may be slower due to network latency and RDAP server
+ * response times and it can be rate limited by RDAP servers... it's also bad actor to spam RDAP servers with requests.
+ */
+func (c *Client) Check(ctx context.Context, domainName string) (bool, string, error) {
+	reg, server, err := c.Lookup(ctx, domainName)
+	return reg != nil, server, err
 }
@@ -0,0 +1,176 @@
+package rdapclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/** startStubServer serves both the bootstrap registry, sending every .test domain to itself, and the rdap server.
+ * Domains are answered by their first label: "taken" is registered, "dropping" is answered with testdata/domain.json,
+ * "slow" is told to retry after 7 seconds, "later" is told to retry at an http date two minutes out and anything else
+ * isn't found.
+ */
+func startStubServer(t *testing.T) (*httptest.Server, time.Time) {
+	t.Helper()
+
+	later := time.Now().Add(2 * time.Minute).Truncate(time.Second)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/bootstrap/dns.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version":"1.0","publication":"2026-01-01T00:00:00Z","services":[[["test"],["%s/rdap/"]]]}`, srv.URL)
+	})
+	mux.HandleFunc("/rdap/domain/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		label, _, _ := strings.Cut(name, ".")
+		switch label {
+		case "taken":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			fmt.Fprintf(w, `{"objectClassName":"domain","ldhName":%q,"status":["active"]}`, strings.ToUpper(name))
+		case "dropping":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			http.ServeFile(w, r, "testdata/domain.json")
+		case "slow":
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "later":
+			w.Header().Set("Retry-After", later.UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	return srv, later
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+
+	c, err := New(Config{
+		BootstrapURL:      srv.URL + "/bootstrap/",
+		BootstrapCacheDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLookup(t *testing.T) {
+	srv, _ := startStubServer(t)
+	c := newTestClient(t, srv)
+	server := srv.URL + "/rdap/"
+
+	if got, err := c.ServerFor(context.Background(), "taken.test"); err != nil || got != server {
+		t.Errorf("ServerFor() = %q, %v, want %q", got, err, server)
+	}
+
+	reg, asked, err := c.Lookup(context.Background(), "taken.test")
+	if err != nil {
+		t.Fatalf("Lookup(taken) error = %v", err)
+	}
+	if reg == nil || reg.Domain != "taken.test" {
+		t.Errorf("Lookup(taken) = %+v, want a registration for taken.test", reg)
+	}
+	if asked != server {
+		t.Errorf("Lookup(taken) server = %q, want %q", asked, server)
+	}
+
+	reg, _, err = c.Lookup(context.Background(), "free.test")
+	if err != nil || reg != nil {
+		t.Errorf("Lookup(free) = %+v, %v, want nil, nil", reg, err)
+	}
+}
+
+func TestLookupRetryAfter(t *testing.T) {
+	srv, later := startStubServer(t)
+	c := newTestClient(t, srv)
+
+	tests := []struct {
+		domain  string
+		min     time.Duration
+		max     time.Duration
+		comment string
+	}{
+		{domain: "slow.test", min: 7 * time.Second, max: 7 * time.Second, comment: "in seconds"},
+		// the date is only as precise as the second it's in
+		{domain: "later.test", min: time.Until(later) - time.Second, max: time.Until(later), comment: "as an http date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comment, func(t *testing.T) {
+			_, _, err := c.Lookup(context.Background(), tt.domain)
+
+			var se *StatusError
+			if !errors.As(err, &se) {
+				t.Fatalf("Lookup() error = %v, want a StatusError", err)
+			}
+			if se.StatusCode != http.StatusTooManyRequests || !se.RateLimited() {
+				t.Errorf("StatusCode = %d, want %d", se.StatusCode, http.StatusTooManyRequests)
+			}
+			if se.Server != srv.URL+"/rdap/" {
+				t.Errorf("Server = %q, want %q", se.Server, srv.URL+"/rdap/")
+			}
+			if se.RetryAfter < tt.min || se.RetryAfter > tt.max {
+				t.Errorf("RetryAfter = %v, want between %v and %v", se.RetryAfter, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestConcurrentLookups(t *testing.T) {
+	srv, _ := startStubServer(t)
+	c := newTestClient(t, srv)
+
+	// the bootstrap registry is downloaded by whoever gets there first, everyone else shares it
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			domain := fmt.Sprintf("taken%d.test", i)
+			if i%2 == 0 {
+				domain = fmt.Sprintf("free%d.test", i)
+			}
+			if _, _, err := c.Lookup(context.Background(), domain); err != nil {
+				t.Errorf("Lookup(%q) error = %v", domain, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewRejectsBadBootstrapURL(t *testing.T) {
+	if _, err := New(Config{BootstrapURL: "not a url"}); !errors.Is(err, RDAPBootstrapURLError) {
+		t.Errorf("New() error = %v, want %v", err, RDAPBootstrapURLError)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{" 5 ", 5 * time.Second},
+		{"-5", 0},
+		{"Thu, 01 Jan 2026 12:01:30 GMT", 90 * time.Second},
+		{"Thu, 01 Jan 2026 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
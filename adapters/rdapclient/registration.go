@@ -0,0 +1,115 @@
+package rdapclient
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrdap/rdap"
+)
+
+/** Registration is what RDAP says about a registered domain. Statuses use the EPP names, eg "clientHold" and
+ * "pendingDelete" rather than RDAP's "client hold" and "pending delete" (RFC 8056), since that's what registrars and
+ * drop lists talk in.
+ */
+type Registration struct {
+	Domain   string
+	Statuses []string
+
+	RegisteredAt  *time.Time
+	ExpiresAt     *time.Time
+	LastChangedAt *time.Time
+
+	Registrar       string
+	RegistrarIANAID string
+
+	Nameservers []string
+}
+
+// HasStatus reports whether the domain has the given EPP status
+func (r *Registration) HasStatus(status string) bool {
+	return slices.Contains(r.Statuses, status)
+}
+
+// eppStatus maps an RDAP status onto its EPP name, eg "redemption period" -> "redemptionPeriod"
+func eppStatus(status string) string {
+	words := strings.Fields(strings.ToLower(status))
+	if len(words) == 0 {
+		return ""
+	}
+	// the one status which isn't just the EPP name split up
+	if len(words) == 1 && words[0] == "active" {
+		return "ok"
+	}
+
+	var b strings.Builder
+	b.WriteString(words[0])
+	for _, w := range words[1:] {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+// eventDate returns the date of the first event with action, nil if there isn't one or it can't be parsed
+func eventDate(events []rdap.Event, action string) *time.Time {
+	for _, event := range events {
+		if !strings.EqualFold(event.Action, action) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, event.Date)
+		if err != nil {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+	return nil
+}
+
+// registrar finds the name and IANA id of the sponsoring registrar among the domain's entities
+func registrar(entities []rdap.Entity) (string, string) {
+	for _, entity := range entities {
+		if !slices.Contains(entity.Roles, "registrar") {
+			continue
+		}
+
+		var name, ianaID string
+		if entity.VCard != nil {
+			name = entity.VCard.Name()
+		}
+		for _, id := range entity.PublicIDs {
+			if strings.EqualFold(id.Type, "IANA Registrar ID") {
+				ianaID = id.Identifier
+				break
+			}
+		}
+		return name, ianaID
+	}
+	return "", ""
+}
+
+func parseRegistration(domainName string, d *rdap.Domain) *Registration {
+	reg := &Registration{
+		Domain: domainName,
+
+		RegisteredAt:  eventDate(d.Events, "registration"),
+		ExpiresAt:     eventDate(d.Events, "expiration"),
+		LastChangedAt: eventDate(d.Events, "last changed"),
+	}
+
+	for _, status := range d.Status {
+		if s := eppStatus(status); s != "" && !slices.Contains(reg.Statuses, s) {
+			reg.Statuses = append(reg.Statuses, s)
+		}
+	}
+
+	reg.Registrar, reg.RegistrarIANAID = registrar(d.Entities)
+
+	for _, ns := range d.Nameservers {
+		if name := strings.TrimSuffix(strings.ToLower(ns.LDHName), "."); name != "" {
+			reg.Nameservers = append(reg.Nameservers, name)
+		}
+	}
+
+	return reg
+}
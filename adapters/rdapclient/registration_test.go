@@ -0,0 +1,75 @@
+package rdapclient
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEPPStatus(t *testing.T) {
+	tests := map[string]string{
+		"active":                     "ok",
+		"Active":                     "ok",
+		"redemption period":          "redemptionPeriod",
+		"pending delete":             "pendingDelete",
+		"client hold":                "clientHold",
+		"client transfer prohibited": "clientTransferProhibited",
+		"  server   hold ":           "serverHold",
+		"inactive":                   "inactive",
+		"":                           "",
+	}
+	for status, want := range tests {
+		if got := eppStatus(status); got != want {
+			t.Errorf("eppStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestLookupParsesRegistration(t *testing.T) {
+	srv, _ := startStubServer(t)
+	c := newTestClient(t, srv)
+
+	reg, _, err := c.Lookup(context.Background(), "dropping.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg == nil {
+		t.Fatal("Lookup() = nil, want a registration")
+	}
+
+	if reg.Domain != "dropping.test" {
+		t.Errorf("domain = %q, want %q", reg.Domain, "dropping.test")
+	}
+	// the repeated status is only kept once
+	if want := []string{"redemptionPeriod", "pendingDelete", "clientHold"}; !slices.Equal(reg.Statuses, want) {
+		t.Errorf("statuses = %v, want %v", reg.Statuses, want)
+	}
+	if !reg.HasStatus("pendingDelete") || reg.HasStatus("ok") {
+		t.Errorf("HasStatus() disagrees with statuses %v", reg.Statuses)
+	}
+
+	dates := []struct {
+		name string
+		got  *time.Time
+		want time.Time
+	}{
+		{"registered at", reg.RegisteredAt, time.Date(1997, 9, 15, 4, 0, 0, 0, time.UTC)},
+		{"expires at", reg.ExpiresAt, time.Date(2026, 9, 14, 4, 0, 0, 0, time.UTC)},
+		// in UTC whatever offset it came with
+		{"last changed at", reg.LastChangedAt, time.Date(2026, 10, 1, 10, 30, 45, 0, time.UTC)},
+	}
+	for _, d := range dates {
+		if d.got == nil || !d.got.Equal(d.want) || d.got.Location() != time.UTC {
+			t.Errorf("%s = %v, want %v", d.name, d.got, d.want)
+		}
+	}
+
+	// the registrar entity, not the first one
+	if reg.Registrar != "Example Registrar, Inc." || reg.RegistrarIANAID != "292" {
+		t.Errorf("registrar = %q (%q), want %q (%q)", reg.Registrar, reg.RegistrarIANAID, "Example Registrar, Inc.", "292")
+	}
+	if want := []string{"ns1.example.net", "ns2.example.net"}; !slices.Equal(reg.Nameservers, want) {
+		t.Errorf("nameservers = %v, want %v", reg.Nameservers, want)
+	}
+}
@@ -0,0 +1,326 @@
+package whoisclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/khinshankhan/nomex/platform/netx"
+)
+
+const (
+	// IANA's server knows which whois server is responsible for each tld
+	defaultIANAServer = "whois.iana.org"
+	whoisPort         = "43"
+)
+
+var (
+	WhoisNoServerError    = errors.New("whoisclient: no whois server for tld")
+	WhoisRateLimitedError = errors.New("whoisclient: rate limited")
+	WhoisUnparsableError  = errors.New("whoisclient: couldn't tell availability from response")
+	WhoisQueryFormatError = errors.New("whoisclient: query format must contain %s")
+)
+
+var (
+	// defaultNotFoundPatterns match the "not found" responses of most registries
+	defaultNotFoundPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?im)^\s*no match\b`),
+		regexp.MustCompile(`(?im)^\s*not found\b`),
+		regexp.MustCompile(`(?im)^\s*(no data found|no entries found|no object found|nothing found)\b`),
+		regexp.MustCompile(`(?im)^\s*%*\s*(domain )?not found\b`),
+		regexp.MustCompile(`(?im)^\s*(domain )?status:\s*(free|available|no object found)\s*$`),
+		regexp.MustCompile(`(?i)\bis (still )?available for registration\b`),
+		regexp.MustCompile(`(?i)\bthe queried object does not exist\b`),
+	}
+
+	// rateLimitPatterns match registries telling us to slow down, which would otherwise read as registered
+	rateLimitPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(query|queries|request|rate) limit (exceeded|reached)\b`),
+		regexp.MustCompile(`(?i)\btoo many (queries|requests)\b`),
+		regexp.MustCompile(`(?i)\bquota exceeded\b`),
+	}
+
+	// registeredPattern is how registries start describing a registered domain, responses matching neither it nor a
+	// not found pattern are refused rather than guessed at
+	registeredPattern = regexp.MustCompile(`(?im)^\s*(domain( name)?|registrar|creation date|created|status|nserver|name server)s?\s*[:.]`)
+
+	// defaultQueryFormats are the queries servers which don't accept a bare domain expect, %s is the domain
+	defaultQueryFormats = map[string]string{
+		"whois.denic.de":         "-T dn,ace %s",
+		"whois.verisign-grs.com": "domain %s",
+	}
+)
+
+// Limit is a token bucket, one query every Every with bursts of up to Burst queries
+type Limit struct {
+	Every time.Duration
+	Burst int
+}
+
+var defaultLimit = Limit{Every: 2 * time.Second, Burst: 2}
+
+type Client struct {
+	timeout      time.Duration
+	ianaServer   string
+	servers      map[string]string
+	patterns     map[string][]*regexp.Regexp
+	queryFormats map[string]string
+	dialer       net.Dialer
+
+	defaultLimit Limit
+	limits       map[string]Limit
+
+	mu         sync.Mutex
+	discovered map[string]string        // tld -> server, "" if the tld has none
+	limiters   map[string]*rate.Limiter // created on first use so every server gets its own budget
+}
+
+type Config struct {
+	Timeout time.Duration // per query, defaults to 10s
+
+	// Servers maps tlds to their whois servers, eg "io": "whois.nic.io". Unlisted tlds are looked up via IANA.
+	Servers map[string]string
+	// IANAServer is asked for the whois server of unlisted tlds, defaults to whois.iana.org
+	IANAServer string
+	// NotFoundPatterns maps tlds to the patterns that mean a domain is available, replacing the defaults for that tld
+	NotFoundPatterns map[string][]*regexp.Regexp
+	// QueryFormats maps servers to the query they expect, eg "whois.denic.de": "-T dn,ace %s"
+	QueryFormats map[string]string
+
+	// DefaultLimit applies to each server without its own limit, defaults to 1 query every 2 seconds with bursts of 2
+	DefaultLimit Limit
+	// Limits by server, eg "whois.nic.io"
+	Limits map[string]Limit
+}
+
+func New(cfg Config) (*Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	queryFormats := make(map[string]string, len(defaultQueryFormats)+len(cfg.QueryFormats))
+	for server, format := range defaultQueryFormats {
+		queryFormats[server] = format
+	}
+	for server, format := range cfg.QueryFormats {
+		if !strings.Contains(format, "%s") {
+			return nil, fmt.Errorf("%w: %q for %s", WhoisQueryFormatError, format, server)
+		}
+		queryFormats[strings.ToLower(server)] = format
+	}
+
+	servers := make(map[string]string, len(cfg.Servers))
+	for tld, server := range cfg.Servers {
+		servers[strings.ToLower(tld)] = strings.ToLower(server)
+	}
+	limit := cfg.DefaultLimit
+	if limit.Every <= 0 || limit.Burst <= 0 {
+		limit = defaultLimit
+	}
+	limits := make(map[string]Limit, len(cfg.Limits))
+	for server, l := range cfg.Limits {
+		limits[strings.ToLower(server)] = l
+	}
+	patterns := make(map[string][]*regexp.Regexp, len(cfg.NotFoundPatterns))
+	for tld, p := range cfg.NotFoundPatterns {
+		patterns[strings.ToLower(tld)] = p
+	}
+	ianaServer := cfg.IANAServer
+	if ianaServer == "" {
+		ianaServer = defaultIANAServer
+	}
+
+	return &Client{
+		timeout:      timeout,
+		ianaServer:   ianaServer,
+		servers:      servers,
+		patterns:     patterns,
+		queryFormats: queryFormats,
+
+		defaultLimit: limit,
+		limits:       limits,
+
+		discovered: make(map[string]string),
+		limiters:   make(map[string]*rate.Limiter),
+	}, nil
+}
+
+func tldOf(domainName string) string {
+	domainName = strings.TrimSuffix(strings.ToLower(domainName), ".")
+	if i := strings.LastIndex(domainName, "."); i >= 0 {
+		return domainName[i+1:]
+	}
+	return domainName
+}
+
+// Server returns the whois server responsible for the tld of domainName
+func (c *Client) Server(ctx context.Context, domainName string) (string, error) {
+	tld := tldOf(domainName)
+	if server, ok := c.servers[tld]; ok {
+		return server, nil
+	}
+
+	c.mu.Lock()
+	server, ok := c.discovered[tld]
+	c.mu.Unlock()
+	if !ok {
+		resp, err := c.Query(ctx, c.ianaServer, tld)
+		if err != nil {
+			return "", err
+		}
+		server = referral(resp)
+
+		c.mu.Lock()
+		c.discovered[tld] = server
+		c.mu.Unlock()
+	}
+
+	if server == "" {
+		return "", fmt.Errorf("%w: %s", WhoisNoServerError, tld)
+	}
+	return server, nil
+}
+
+// referral finds the server IANA refers us to, ie the "refer:" or "whois:" line, "" if there isn't one
+func referral(resp string) string {
+	scanner := bufio.NewScanner(strings.NewReader(resp))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "refer", "whois":
+			if server := strings.TrimSpace(value); server != "" {
+				return strings.ToLower(server)
+			}
+		}
+	}
+	return ""
+}
+
+func (c *Client) limiter(server string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.limiters[server]; ok {
+		return l
+	}
+	limit, ok := c.limits[server]
+	if !ok {
+		limit = c.defaultLimit
+	}
+	l := rate.NewLimiter(rate.Every(limit.Every), limit.Burst)
+	c.limiters[server] = l
+	return l
+}
+
+/** Query sends a raw query to a whois server and returns the response, as described in RFC 3912. Queries to each server
+ * are rate limited, waiting for the server's limiter doesn't count towards the timeout.
+ */
+func (c *Client) Query(ctx context.Context, server string, query string) (string, error) {
+	if err := c.limiter(strings.ToLower(server)).Wait(ctx); err != nil {
+		// the limiter gives up early if the wait would outlast ctx, which is as good as timing out
+		if ctx.Err() == nil {
+			return "", fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+		}
+		return "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, whoisPort)
+	}
+	conn, err := c.dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	unbind, err := netx.BindContext(ctx, conn, 0)
+	if err != nil {
+		return "", err
+	}
+	defer unbind()
+
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", err
+	}
+
+	// the server closes the connection once it's done responding
+	resp, err := io.ReadAll(io.LimitReader(conn, netx.MaxResponseSize))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", err
+	}
+	return string(resp), nil
+}
+
+func (c *Client) queryFor(server string, domainName string) string {
+	if format, ok := c.queryFormats[server]; ok {
+		return fmt.Sprintf(format, domainName)
+	}
+	return domainName
+}
+
+func (c *Client) available(tld string, resp string) (bool, error) {
+	for _, p := range rateLimitPatterns {
+		if p.MatchString(resp) {
+			return false, WhoisRateLimitedError
+		}
+	}
+
+	patterns, ok := c.patterns[tld]
+	if !ok {
+		patterns = defaultNotFoundPatterns
+	}
+	for _, p := range patterns {
+		if p.MatchString(resp) {
+			return true, nil
+		}
+	}
+
+	if !registeredPattern.MatchString(resp) {
+		return false, WhoisUnparsableError
+	}
+	return false, nil
+}
+
+/** Check checks if a domain name is taken using WHOIS. Returns true if taken, false if available, and error if any
+ * other error occurs. The whois server that was asked is returned as well, if one was found.
+ *
+ * NOTE: WHOIS responses are free form text so availability is read off of per-TLD patterns, this is meant as a fallback
+ * for TLDs without RDAP rather than a replacement for it.
+ */
+func (c *Client) Check(ctx context.Context, domainName string) (bool, string, error) {
+	server, err := c.Server(ctx, domainName)
+	if err != nil {
+		return false, "", err
+	}
+
+	resp, err := c.Query(ctx, server, c.queryFor(server, strings.ToLower(domainName)))
+	if err != nil {
+		return false, server, err
+	}
+
+	available, err := c.available(tldOf(domainName), resp)
+	if err != nil {
+		return false, server, err
+	}
+	return !available, server, nil
+}
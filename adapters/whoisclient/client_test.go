@@ -0,0 +1,236 @@
+package whoisclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServer answers every query with whatever respond returns for it
+func startServer(t *testing.T, respond func(query string) string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				query, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				io.WriteString(conn, respond(strings.TrimSpace(query)))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// startStubServer answers every query with a "no match" unless the domain starts with "taken"
+func startStubServer(t *testing.T) string {
+	t.Helper()
+
+	return startServer(t, func(domain string) string {
+		if strings.HasPrefix(domain, "taken") {
+			return fmt.Sprintf("Domain Name: %s\r\nRegistrar: Example\r\n", strings.ToUpper(domain))
+		}
+		return fmt.Sprintf("No match for %q.\r\n", domain)
+	})
+}
+
+func TestCheck(t *testing.T) {
+	server := startStubServer(t)
+	c, err := New(Config{Servers: map[string]string{"test": server}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for domain, want := range map[string]bool{"taken.test": true, "free.test": false} {
+		taken, asked, err := c.Check(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", domain, err)
+		}
+		if taken != want {
+			t.Errorf("Check(%q) = %v, want %v", domain, taken, want)
+		}
+		if asked != server {
+			t.Errorf("Check(%q) server = %q, want %q", domain, asked, server)
+		}
+	}
+}
+
+func TestQueryIsRateLimitedPerServer(t *testing.T) {
+	slow, fast := startStubServer(t), startStubServer(t)
+	every := 100 * time.Millisecond
+	c, err := New(Config{
+		Servers:      map[string]string{"slow": slow, "fast": fast},
+		DefaultLimit: Limit{Every: every, Burst: 1},
+		Limits:       map[string]Limit{fast: {Every: time.Millisecond, Burst: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for range 3 {
+		if _, _, err := c.Check(context.Background(), "free.slow"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first query goes out straight away, every other one waits its turn
+	if took := time.Since(start); took < 2*every {
+		t.Errorf("3 queries to the same server took %v, want at least %v", took, 2*every)
+	}
+
+	// another server has its own budget
+	start = time.Now()
+	for range 3 {
+		if _, _, err := c.Check(context.Background(), "free.fast"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took >= every {
+		t.Errorf("3 queries to another server took %v, want less than %v", took, every)
+	}
+}
+
+func TestQueryGivesUpIfTheLimiterOutlastsCtx(t *testing.T) {
+	server := startStubServer(t)
+	c, err := New(Config{
+		Servers:      map[string]string{"test": server},
+		DefaultLimit: Limit{Every: time.Hour, Burst: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Check(context.Background(), "free.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, _, err := c.Check(ctx, "free.test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestServerIsDiscoveredViaIANA(t *testing.T) {
+	registry := startStubServer(t)
+	var asked atomic.Int32
+	iana := startServer(t, func(tld string) string {
+		asked.Add(1)
+		if tld == "test" {
+			return fmt.Sprintf("%% IANA WHOIS server\r\n\r\ndomain:       TEST\r\nrefer:        %s\r\n", registry)
+		}
+		return "% IANA WHOIS server\r\n\r\ndomain:       NONE\r\n"
+	})
+	c, err := New(Config{IANAServer: iana, DefaultLimit: Limit{Every: time.Millisecond, Burst: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for domain, want := range map[string]bool{"taken.test": true, "free.test": false} {
+		taken, server, err := c.Check(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", domain, err)
+		}
+		if taken != want || server != registry {
+			t.Errorf("Check(%q) = %v from %q, want %v from %q", domain, taken, server, want, registry)
+		}
+	}
+	// the referral is remembered for the tld
+	if got := asked.Load(); got != 1 {
+		t.Errorf("IANA was asked %d times, want 1", got)
+	}
+
+	// and so is a tld without a whois server
+	for range 2 {
+		if _, _, err := c.Check(context.Background(), "example.none"); !errors.Is(err, WhoisNoServerError) {
+			t.Errorf("Check() error = %v, want %v", err, WhoisNoServerError)
+		}
+	}
+	if got := asked.Load(); got != 2 {
+		t.Errorf("IANA was asked %d times, want 2", got)
+	}
+}
+
+func TestReferral(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		want string
+	}{
+		{name: "refer", resp: "domain:       IO\r\nrefer:        WHOIS.NIC.IO\r\n", want: "whois.nic.io"},
+		{name: "whois", resp: "domain: DE\nwhois: whois.denic.de\n", want: "whois.denic.de"},
+		{name: "empty refer", resp: "refer:\nwhois: whois.nic.example\n", want: "whois.nic.example"},
+		{name: "none", resp: "domain:       EXAMPLE\r\nstatus:       ACTIVE\r\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referral(tt.resp); got != tt.want {
+				t.Errorf("referral() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	c, err := New(Config{
+		// replaces the defaults for the tld rather than adding to them
+		NotFoundPatterns: map[string][]*regexp.Regexp{"EX": {regexp.MustCompile(`(?m)^% object does not exist$`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		tld     string
+		resp    string
+		want    bool
+		wantErr error
+	}{
+		{name: "no match", tld: "com", resp: "No match for \"EXAMPLE.COM\".\r\n", want: true},
+		{name: "not found", tld: "com", resp: "NOT FOUND\n", want: true},
+		{name: "status free", tld: "com", resp: "Domain: example.com\nStatus: free\n", want: true},
+		{name: "available for registration", tld: "com", resp: "The domain example.com is available for registration.\n", want: true},
+		{name: "registered", tld: "com", resp: "Domain Name: EXAMPLE.COM\r\nRegistrar: Example\r\n", want: false},
+		{name: "nserver", tld: "com", resp: "nserver: ns1.example.com\n", want: false},
+		{name: "unparsable", tld: "com", resp: "Welcome to our whois service!\n", wantErr: WhoisUnparsableError},
+		{name: "query limit exceeded", tld: "com", resp: "Query limit exceeded, try again later\n", wantErr: WhoisRateLimitedError},
+		{name: "too many requests", tld: "com", resp: "Domain Name: EXAMPLE.COM\nToo many requests\n", wantErr: WhoisRateLimitedError},
+		{name: "quota exceeded", tld: "com", resp: "Your quota exceeded\n", wantErr: WhoisRateLimitedError},
+		{name: "tld pattern", tld: "ex", resp: "% object does not exist\n", want: true},
+		{name: "defaults don't apply to a tld with its own", tld: "ex", resp: "No match for \"EXAMPLE.EX\".\n", wantErr: WhoisUnparsableError},
+		{name: "tld registered", tld: "ex", resp: "domain: example.ex\nstatus: connect\n", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.available(tt.tld, tt.resp)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("available() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("available() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/domainban"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// runBan handles `gather-cli ban [flags] <domain>...`
+func runBan(args []string) {
+	fs := flag.NewFlagSet("ban", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	reason := fs.String("reason", "manual", "reason recorded with the ban")
+	duration := fs.Duration("for", 0, "lift the ban automatically after this long, 0 bans permanently")
+	_ = fs.Parse(args)
+
+	domains := fs.Args()
+	if len(domains) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ban [flags] <domain>...")
+		os.Exit(2)
+	}
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domainbanRepo := domainban.NewRepository(conn)
+
+	t := time.Now()
+	category := domainban.CategoryPermanent
+	var expiresAt *time.Time
+	if *duration > 0 {
+		category = domainban.CategoryTransient
+		expiry := t.Add(*duration)
+		expiresAt = &expiry
+	}
+
+	for _, d := range domains {
+		err := domainbanRepo.BanDomain(
+			domainban.DomainBan{
+				Domain:    strings.ToLower(d),
+				Reason:    reason,
+				At:        &t,
+				Category:  category,
+				ExpiresAt: expiresAt,
+			},
+		)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("Banned domain", fields.String("domain", d))
+	}
+}
+
+// runUnban handles `gather-cli unban [flags] <domain>...`
+func runUnban(args []string) {
+	fs := flag.NewFlagSet("unban", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	_ = fs.Parse(args)
+
+	domains := fs.Args()
+	if len(domains) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: unban [flags] <domain>...")
+		os.Exit(2)
+	}
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domainbanRepo := domainban.NewRepository(conn)
+
+	for _, d := range domains {
+		found, err := domainbanRepo.UnbanDomain(strings.ToLower(d))
+		if err != nil {
+			panic(err)
+		}
+		if !found {
+			logger.Warn("Domain was not banned", fields.String("domain", d))
+			continue
+		}
+		logger.Info("Unbanned domain", fields.String("domain", d))
+	}
+}
@@ -2,28 +2,104 @@ package main
 
 import (
 	"fmt"
+	"iter"
+	"math/big"
+	"os"
+	"time"
 
 	"github.com/khinshankhan/nomex/data/domainban"
 	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/platform/pattern"
+	"github.com/khinshankhan/nomex/platform/wordlist"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/utils"
 )
 
-// TODO: we need to greatly decrease the candidate space
-// conversation on TPH https://discord.com/channels/244230771232079873/244230771232079873/1435352534821765220
-func generateCandidates(tlds []string) []string {
-	candidates := []string{}
+// generateCandidates streams every pattern (see platform/pattern) as domain names along with the total size of the
+// space, refusing if the space is larger than limit so we don't accidentally queue an absurd number of names
+func generateCandidates(patterns []string, limit int64) (iter.Seq[string], *big.Int, error) {
+	logger := logx.GetDefaultLogger()
 
-	// TODO: surely there's a more efficient way to do this
-	for _, tld := range tlds {
-		for c1 := 'a'; c1 <= 'z'; c1++ {
-			candidates = append(candidates, fmt.Sprintf("%c.%s", c1, tld))
+	seqs := make([]iter.Seq[string], 0, len(patterns))
+	total := new(big.Int)
+	for _, raw := range patterns {
+		p, err := pattern.Parse(raw)
+		if err != nil {
+			return nil, nil, err
 		}
+
+		cardinality := p.Cardinality()
+		logger.Info(
+			"Parsed pattern",
+			fields.String("pattern", raw),
+			fields.String("cardinality", cardinality.String()),
+		)
+
+		total.Add(total, cardinality)
+		seqs = append(seqs, p.All())
 	}
 
-	return candidates
+	if total.Cmp(big.NewInt(limit)) > 0 {
+		return nil, nil, fmt.Errorf("candidate space of %s exceeds limit of %d", total, limit)
+	}
+
+	return utils.ConcatSeq(seqs...), total, nil
 }
 
-func filterBadCandidates(domainbanRepo domainban.Repository, domains []domaincheck.DomainCheck) []string {
-	bannedDomainRecords, err := domainbanRepo.GetAllBannedDomains()
+/** wordlistCandidates streams every word from the wordlist files crossed with the affixes and tlds in opts, the files
+ * are only read as the stream is consumed. A file which can't be read ends the stream with its error rather than
+ * panicking, since the caller may have a transaction open while draining it.
+ */
+func wordlistCandidates(paths []string, opts wordlist.Options) (iter.Seq2[string, error], error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no wordlists provided")
+	}
+	// fail early rather than midway through generation
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(yield func(string, error) bool) {
+		var err error
+		words := func(yield func(string) bool) {
+			for _, path := range paths {
+				f, openErr := os.Open(path)
+				if openErr != nil {
+					err = openErr
+					return
+				}
+				more := true
+				for word := range utils.TakeUntilErr(wordlist.Words(f), &err) {
+					if !yield(word) {
+						more = false
+						break
+					}
+				}
+				f.Close()
+				if !more || err != nil {
+					return
+				}
+			}
+		}
+
+		for candidate := range wordlist.Expand(words, opts) {
+			if !yield(candidate, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield("", err)
+		}
+	}, nil
+}
+
+// filterBadCandidates drops banned domains from the stream, errors are passed through for the caller to handle
+func filterBadCandidates(domainbanRepo domainban.Repository, domains iter.Seq2[domaincheck.DomainCheck, error]) iter.Seq2[string, error] {
+	// expired transient bans are left out so those domains get another go
+	bannedDomainRecords, err := domainbanRepo.GetActiveBans(time.Now())
 	if err != nil {
 		panic(err)
 	}
@@ -33,14 +109,19 @@ func filterBadCandidates(domainbanRepo domainban.Repository, domains []domainche
 		bannedDomainLookup[record.Domain] = struct{}{}
 	}
 
-	var filtered []string
-	for _, d := range domains {
-		if _, found := bannedDomainLookup[d.Domain]; found {
-			continue
-		}
+	return func(yield func(string, error) bool) {
+		for d, err := range domains {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if _, found := bannedDomainLookup[d.Domain]; found {
+				continue
+			}
 
-		filtered = append(filtered, d.Domain)
+			if !yield(d.Domain, nil) {
+				return
+			}
+		}
 	}
-
-	return filtered
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/khinshankhan/nomex/data/domainban"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// runCheck handles `gather-cli check [flags]`, verifying every pending candidate
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	tlds := fs.String("tlds", "", "comma separated tlds to check, empty checks every pending domain")
+	concurrency := fs.Int("concurrency", 16, "number of domains verified in parallel")
+	batchSize := fs.Int("batch-size", domaincheck.DefaultPendingPageSize, "number of pending domains loaded and verified at a time")
+	verifier := addVerifierFlags(fs, "zonefile,dns,rdap,whois")
+	_ = fs.Parse(args)
+
+	if *batchSize <= 0 {
+		*batchSize = domaincheck.DefaultPendingPageSize
+	}
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domaincheckRepo := domaincheck.NewRepository(conn)
+	domainbanRepo := domainban.NewRepository(conn)
+
+	// pending domains are streamed and verified a batch at a time so memory stays bounded however many are queued
+	tldList := splitList(*tlds)
+	pendingDomains := filterBadCandidates(domainbanRepo, domaincheckRepo.StreamPendingDomains(*batchSize))
+
+	// verify domains
+	verifydomainUsecase, done := verifier.newVerifier(conn)
+	defer done()
+
+	// cancel on SIGINT/SIGTERM so workers wrap up in-flight domains, anything unchecked stays pending for the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-finished:
+			return
+		}
+		// the deferred stop also closes ctx once the batch is done, that's not a shutdown
+		select {
+		case <-finished:
+			return
+		default:
+		}
+
+		// restore default handling so a second signal kills the process outright
+		stop()
+		logger.Warn("Shutting down, waiting for in-flight domains (signal again to force quit)")
+	}()
+
+	n := 0
+	batch := make([]string, 0, *batchSize)
+	verifyBatch := func() {
+		_ = verifydomainUsecase.VerifyBatch(*concurrency, ctx, batch)
+		n += len(batch)
+		batch = batch[:0]
+	}
+	for d, err := range pendingDomains {
+		if err != nil {
+			panic(err)
+		}
+		if !inTLDs(d, tldList) {
+			continue
+		}
+
+		batch = append(batch, d)
+		if len(batch) >= *batchSize {
+			verifyBatch()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(batch) > 0 && ctx.Err() == nil {
+		verifyBatch()
+	}
+	close(finished)
+
+	logger.Info(
+		"Checked candidates",
+		fields.Int("n", n),
+	)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"time"
+
+	"github.com/khinshankhan/nomex/adapters/dnsresolver"
+	"github.com/khinshankhan/nomex/data/dnscache"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// restoreDNSCache fills cache with the answers persisted by a previous run which are still valid
+func restoreDNSCache(dnscacheRepo dnscache.Repository, cache *dnsresolver.Cache) {
+	logger := logx.GetDefaultLogger()
+
+	entries, err := dnscacheRepo.GetUnexpiredEntries(time.Now())
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		cache.Put(entry.Domain, dnsresolver.Status(entry.Status), entry.ExpiresAt)
+	}
+
+	logger.Info(
+		"Restored dns cache",
+		fields.Int("n", len(entries)),
+	)
+}
+
+// persistDNSCache saves every answer in cache which is still valid so the next run can pick up where this one left off
+func persistDNSCache(dnscacheRepo dnscache.Repository, cache *dnsresolver.Cache) {
+	logger := logx.GetDefaultLogger()
+
+	cached := cache.Entries(time.Now())
+	entries := make([]dnscache.Entry, 0, len(cached))
+	for _, entry := range cached {
+		entries = append(entries, dnscache.Entry{
+			Domain:    entry.Domain,
+			Status:    string(entry.Status),
+			ExpiresAt: entry.ExpiresAt,
+		})
+	}
+
+	if err := dnscacheRepo.ReplaceEntries(entries); err != nil {
+		logger.Error("failed to persist dns cache", fields.Error(err))
+		return
+	}
+	logger.Info(
+		"Persisted dns cache",
+		fields.Int("n", len(entries)),
+	)
+}
+
+func logDNSCacheStats(cache *dnsresolver.Cache) {
+	logger := logx.GetDefaultLogger()
+
+	stats := cache.Stats()
+	logger.Info(
+		"DNS cache stats",
+		fields.Uint64("hits", stats.Hits),
+		fields.Uint64("misses", stats.Misses),
+		fields.Int("entries", stats.Entries),
+	)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+
+	"github.com/khinshankhan/nomex/adapters/eppclient"
+)
+
+// newEPPClient configures an EPP client from the environment, the registrar account's credentials shouldn't be
+// passed as flags
+func newEPPClient() *eppclient.Client {
+	cfg := eppclient.Config{
+		Address:  os.Getenv("EPP_ADDRESS"),
+		ClientID: os.Getenv("EPP_CLIENT_ID"),
+		Password: os.Getenv("EPP_PASSWORD"),
+	}
+	if cfg.Address == "" {
+		panic("EPP_ADDRESS environment variable is not set")
+	}
+
+	// most registries only accept connections presenting the registrar's certificate
+	certFile, keyFile := os.Getenv("EPP_CERT_FILE"), os.Getenv("EPP_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			panic(err)
+		}
+		cfg.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	eppClient, err := eppclient.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return eppClient
+}
+
+// eppTLDs are the tlds the registrar account serves, the epp checker passes every other domain on down the chain
+func eppTLDs() []string {
+	tlds := splitList(os.Getenv("EPP_TLDS"))
+	if len(tlds) == 0 {
+		panic("EPP_TLDS environment variable is not set")
+	}
+	return tlds
+}
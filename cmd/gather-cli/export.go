@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// runExport handles `gather-cli export [flags]`, writing available domains one per line
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	out := fs.String("out", "available-domains.txt", "output file, - for stdout")
+	tlds := fs.String("tlds", "", "comma separated tlds to export, empty exports every available domain")
+	_ = fs.Parse(args)
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domaincheckRepo := domaincheck.NewRepository(conn)
+
+	availableDomains, err := domaincheckRepo.GetAvailableDomains()
+	if err != nil {
+		panic(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	tldList := splitList(*tlds)
+	n := 0
+	for _, d := range availableDomains {
+		if !inTLDs(d.Domain, tldList) {
+			continue
+		}
+		bw.WriteString(d.Domain + "\n")
+		n++
+	}
+	if err := bw.Flush(); err != nil {
+		panic(err)
+	}
+
+	logger.Info(
+		"Exported available domains",
+		fields.Int("n", n),
+		fields.String("out", *out),
+	)
+}
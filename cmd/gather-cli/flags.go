@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khinshankhan/nomex/adapters/whoisclient"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/usecases/verifydomain"
+)
+
+const defaultDBPath = "db/domains.sqlite"
+
+func dbFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", defaultDBPath, "path to the sqlite database")
+}
+
+func openDatabase(path string) *sql.DB {
+	conn, err := sqlite.GetConnection(
+		sqlite.DefaultOptions(path),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return conn
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// inTLDs reports whether domain belongs to one of tlds, an empty list matches everything
+func inTLDs(domain string, tlds []string) bool {
+	if len(tlds) == 0 {
+		return true
+	}
+	for _, tld := range tlds {
+		if strings.HasSuffix(domain, "."+strings.TrimPrefix(tld, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRate parses a rate like "15s/5", one request every 15 seconds with bursts of up to 5
+func parseRate(what string, s string) (time.Duration, int) {
+	every, burst, ok := strings.Cut(s, "/")
+	if !ok {
+		panic(fmt.Sprintf("invalid %s rate %q, expected <interval>/<burst>", what, s))
+	}
+	d, err := time.ParseDuration(every)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s rate %q: %v", what, s, err))
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || d <= 0 || b <= 0 {
+		panic(fmt.Sprintf("invalid %s rate %q, interval and burst must be positive", what, s))
+	}
+	return d, b
+}
+
+// parseServerRates parses comma separated per server rates like "whois.nic.io=1s/10"
+func parseServerRates(what string, s string) map[string]string {
+	rates := make(map[string]string)
+	for _, entry := range splitList(s) {
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			panic(fmt.Sprintf("invalid %s server rate %q, expected <server>=<interval>/<burst>", what, entry))
+		}
+		rates[entry[:i]] = entry[i+1:]
+	}
+	return rates
+}
+
+func parseRDAPLimit(s string) verifydomain.RDAPLimit {
+	every, burst := parseRate("rdap", s)
+	return verifydomain.RDAPLimit{Every: every, Burst: burst}
+}
+
+// parseRDAPLimits parses comma separated per server rates like "https://rdap.verisign.com/com/v1/=1s/10"
+func parseRDAPLimits(s string) map[string]verifydomain.RDAPLimit {
+	limits := make(map[string]verifydomain.RDAPLimit)
+	for server, rate := range parseServerRates("rdap", s) {
+		// servers are keyed by their bootstrapped base url which always ends in a slash
+		if !strings.HasSuffix(server, "/") {
+			server += "/"
+		}
+		limits[server] = parseRDAPLimit(rate)
+	}
+	return limits
+}
+
+func parseWHOISLimit(s string) whoisclient.Limit {
+	every, burst := parseRate("whois", s)
+	return whoisclient.Limit{Every: every, Burst: burst}
+}
+
+// parseWHOISLimits parses comma separated per server rates like "whois.nic.io=5s/1"
+func parseWHOISLimits(s string) map[string]whoisclient.Limit {
+	limits := make(map[string]whoisclient.Limit)
+	for server, rate := range parseServerRates("whois", s) {
+		limits[server] = parseWHOISLimit(rate)
+	}
+	return limits
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"iter"
+	"math/big"
+
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/platform/wordlist"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/utils"
+)
+
+// runGenerate handles `gather-cli generate [flags]`, queueing candidates as pending checks
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	source := fs.String("source", "pattern", "candidate source, either pattern or wordlist")
+	patterns := fs.String("patterns", "L.net", "comma separated candidate patterns, eg CVC.net,[a-z0-9]{3}.io")
+	maxCandidates := fs.Int64("max-candidates", 1_000_000, "refuse patterns whose combined space is larger than this")
+	wordlists := fs.String("wordlists", "", "comma separated wordlist files, one word per line")
+	tlds := fs.String("tlds", "net", "comma separated tlds to cross wordlist candidates with")
+	minLength := fs.Int("min-length", 0, "minimum wordlist label length, 0 for no minimum")
+	maxLength := fs.Int("max-length", 0, "maximum wordlist label length, 0 for no maximum")
+	prefixes := fs.String("prefixes", "", "comma separated prefixes for wordlist candidates, eg get,try")
+	suffixes := fs.String("suffixes", "", "comma separated suffixes for wordlist candidates, eg -hq,-app")
+	chunkSize := fs.Int("chunk-size", domaincheck.DefaultEnsureChunkSize, "number of candidates committed per transaction")
+	_ = fs.Parse(args)
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domaincheckRepo := domaincheck.NewRepository(conn)
+
+	var generatedCandidates iter.Seq[string]
+	var err, streamErr error
+	switch *source {
+	case "pattern":
+		var cardinality *big.Int
+		generatedCandidates, cardinality, err = generateCandidates(splitList(*patterns), *maxCandidates)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(
+			"Generating candidates",
+			fields.String("n", cardinality.String()),
+		)
+	case "wordlist":
+		var words iter.Seq2[string, error]
+		words, err = wordlistCandidates(splitList(*wordlists), wordlist.Options{
+			MinLength: *minLength,
+			MaxLength: *maxLength,
+			Prefixes:  splitList(*prefixes),
+			Suffixes:  splitList(*suffixes),
+			TLDs:      splitList(*tlds),
+		})
+		if err != nil {
+			panic(err)
+		}
+		generatedCandidates = utils.TakeUntilErr(words, &streamErr)
+	default:
+		panic(fmt.Sprintf("unknown candidate source %q, expected pattern or wordlist", *source))
+	}
+
+	// ensure all candidates are in the database so they're "queued" for checking
+	n, err := domaincheckRepo.StreamEnsureDomainChecks(generatedCandidates, *chunkSize)
+	if err != nil {
+		panic(err)
+	}
+	// whatever was generated before a wordlist failed is committed, the error is only raised once the stream is closed
+	if streamErr != nil {
+		panic(streamErr)
+	}
+	logger.Info(
+		"Generated candidates",
+		fields.Int("n", n),
+	)
+}
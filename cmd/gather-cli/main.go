@@ -1,20 +1,11 @@
 package main
 
 import (
-	"context"
+	"fmt"
 	"math/rand"
 	"os"
 	"time"
 
-	"github.com/khinshankhan/nomex/adapters/dnsresolver"
-	"github.com/khinshankhan/nomex/adapters/rdapclient"
-	"github.com/khinshankhan/nomex/data/domainban"
-	"github.com/khinshankhan/nomex/data/domaincheck"
-	"github.com/khinshankhan/nomex/infra/sqlite"
-	"github.com/khinshankhan/nomex/services/logx"
-	"github.com/khinshankhan/nomex/services/logx/fields"
-	"github.com/khinshankhan/nomex/usecases/verifydomain"
-
 	"github.com/joho/godotenv"
 )
 
@@ -28,94 +19,55 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-// TODO: make this a flag
-const CHECK_DOMAINS = true
-
 // Version and BuildData get replaced during build with the commit hash and time of build
 var (
 	CommitHash = ""
 	BuildDate  = ""
 )
 
-func main() {
-	logger := logx.GetDefaultLogger()
-	conn, err := sqlite.GetConnection(
-		sqlite.DefaultOptions("db/domains.sqlite"),
-	)
-	if err != nil {
-		panic(err)
-	}
-	defer sqlite.CloseConnection(conn)
-
-	domaincheckRepo := domaincheck.NewRepository(conn)
-	domainbanRepo := domainban.NewRepository(conn)
-
-	if CHECK_DOMAINS {
-		generatedCandidates := generateCandidates([]string{"net"})
-		logger.Info(
-			"Generated candidates",
-			fields.Int("n", len(generatedCandidates)),
-		)
-
-		// ensure all candidates are in the database so they're "queued" for checking
-		err = domaincheckRepo.BulkEnsureDomainChecks(generatedCandidates)
-		if err != nil {
-			panic(err)
-		}
-
-		// NOTE: this loads any pre existing pending domains from the database
-		pendingDomains, err := domaincheckRepo.GetPendingDomains()
-		if err != nil {
-			panic(err)
-		}
-		logger.Info(
-			"Loaded candidates",
-			fields.Int("n", len(pendingDomains)),
-		)
-
-		// list of domain names to check
-		candidates := filterBadCandidates(domainbanRepo, pendingDomains)
-		logger.Info(
-			"Filtered candidates",
-			fields.Int("n", len(candidates)),
-		)
-
-		// verify domains
-		ua := getUserAgent()
-		rdapClient, err := rdapclient.New(rdapclient.Config{
-			UserAgent:  ua,
-			HTTPClient: nil, // use default 10s
-		})
-		if err != nil {
-			panic(err)
-		}
-
-		dnsResolver := dnsresolver.New(dnsresolver.Config{
-			Timeout: 30 * time.Second,
-		})
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
 
-		verifydomainUsecase := verifydomain.New(
-			domaincheckRepo,
-			domainbanRepo,
-			dnsResolver,
-			rdapClient,
-		)
+var commands = []command{
+	{"generate", "queue candidates from patterns or wordlists", runGenerate},
+	{"check", "verify pending candidates over DNS and RDAP", runCheck},
+	{"export", "write available domains to a file", runExport},
+	{"stats", "summarize check results", runStats},
+	{"ban", "exclude domains from checking", runBan},
+	{"unban", "lift bans on domains", runUnban},
+	{"zone", "load or report zone files used to skip registered domains", runZone},
+	{"watch", "watch domains about to drop and recheck them around their drop", runWatch},
+	{"migrate", "apply, roll back or report schema migrations", runMigrate},
+}
 
-		maxParallel := 16
-		_ = verifydomainUsecase.VerifyBatch(maxParallel, context.Background(), candidates)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
 	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for a command's flags\n", os.Args[0])
+}
 
-	availableDomains, err := domaincheckRepo.GetAvailableDomains()
-	if err != nil {
-		panic(err)
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
 
-	f, err := os.Create("available-domains.txt")
-	if err != nil {
-		panic(err)
+	name, args := os.Args[1], os.Args[2:]
+	for _, c := range commands {
+		if c.name == name {
+			c.run(args)
+			return
+		}
 	}
-	defer f.Close()
-	for _, d := range availableDomains {
-		f.WriteString(d.Domain + "\n")
+
+	if name != "-h" && name != "-help" && name != "--help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
 	}
+	usage()
+	os.Exit(2)
 }
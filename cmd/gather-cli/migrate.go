@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// runMigrate handles `gather-cli migrate [up|down|status] [flags]`
+func runMigrate(args []string) {
+	logger := logx.GetDefaultLogger()
+
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	steps := fs.Int("steps", 1, "number of migrations to roll back when running down")
+	_ = fs.Parse(args)
+
+	opts := sqlite.DefaultOptions(*dbPath)
+	// migrations are driven explicitly by the subcommand
+	opts.Migrate = false
+	conn, err := sqlite.GetConnection(opts)
+	if err != nil {
+		panic(err)
+	}
+	defer sqlite.CloseConnection(conn)
+
+	switch action {
+	case "up":
+		n, err := sqlite.MigrateUp(conn)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("Applied migrations", fields.Int("n", n))
+	case "down":
+		n, err := sqlite.MigrateDown(conn, *steps)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("Rolled back migrations", fields.Int("n", n))
+	case "status":
+		statuses, err := sqlite.GetMigrationStatus(conn)
+		if err != nil {
+			panic(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q, expected up, down or status\n", action)
+		os.Exit(2)
+	}
+}
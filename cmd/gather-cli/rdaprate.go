@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+
+	"github.com/khinshankhan/nomex/data/rdaprate"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/usecases/verifydomain"
+)
+
+// restoreRDAPRates fills rates with what a previous run learned about each rdap server
+func restoreRDAPRates(rdaprateRepo rdaprate.Repository, rates *verifydomain.RDAPRates) {
+	logger := logx.GetDefaultLogger()
+
+	persisted, err := rdaprateRepo.GetRates()
+	if err != nil {
+		panic(err)
+	}
+	for _, rate := range persisted {
+		rates.Set(rate.Server, rate.Interval)
+	}
+
+	logger.Info(
+		"Restored rdap rates",
+		fields.Int("n", len(persisted)),
+	)
+}
+
+// persistRDAPRates saves the rate each rdap server ended up at so the next run starts there
+func persistRDAPRates(rdaprateRepo rdaprate.Repository, rates *verifydomain.RDAPRates) {
+	logger := logx.GetDefaultLogger()
+
+	now := time.Now()
+	learned := rates.Entries()
+	persisted := make([]rdaprate.Rate, 0, len(learned))
+	for server, interval := range learned {
+		persisted = append(persisted, rdaprate.Rate{
+			Server:    server,
+			Interval:  interval,
+			UpdatedAt: now,
+		})
+		logger.Info(
+			"Learned rdap rate",
+			fields.String("server", server),
+			fields.Duration("interval", interval),
+		)
+	}
+
+	if err := rdaprateRepo.SaveRates(persisted); err != nil {
+		logger.Error("failed to persist rdap rates", fields.Error(err))
+		return
+	}
+	logger.Info(
+		"Persisted rdap rates",
+		fields.Int("n", len(persisted)),
+	)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/khinshankhan/nomex/data/domainban"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+// runStats handles `gather-cli stats [flags]`, printing how many domains landed on each result
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	_ = fs.Parse(args)
+
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	domaincheckRepo := domaincheck.NewRepository(conn)
+	domainbanRepo := domainban.NewRepository(conn)
+
+	counts, err := domaincheckRepo.GetAvailabilityCounts()
+	if err != nil {
+		panic(err)
+	}
+	bans, err := domainbanRepo.GetAllBannedDomains()
+	if err != nil {
+		panic(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AVAILABILITY\tERROR CLASS\tDOMAINS")
+	total := 0
+	for _, c := range counts {
+		availability := "pending"
+		if c.Availability != nil {
+			availability = string(*c.Availability)
+		}
+		errorClass := "-"
+		if c.ErrorClass != nil {
+			errorClass = string(*c.ErrorClass)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\n", availability, errorClass, c.N)
+		total += c.N
+	}
+	fmt.Fprintf(w, "total\t\t%d\n", total)
+	fmt.Fprintf(w, "banned\t\t%d\n", len(bans))
+	w.Flush()
+}
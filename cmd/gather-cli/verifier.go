@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khinshankhan/nomex/adapters/dnsresolver"
+	"github.com/khinshankhan/nomex/adapters/eppclient"
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+	"github.com/khinshankhan/nomex/adapters/whoisclient"
+	"github.com/khinshankhan/nomex/data/dnscache"
+	"github.com/khinshankhan/nomex/data/domainban"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/data/rdaprate"
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/zonedomain"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/usecases/verifydomain"
+)
+
+// verifierFlags configure the checker chain, shared by every command which verifies domains
+type verifierFlags struct {
+	checkers             *string
+	dnsTimeout           *time.Duration
+	nameservers          *string
+	dnsStrategy          *string
+	dnsTransport         *string
+	dnsMode              *string
+	dnsIPv6              *bool
+	dnsHTTPSMethod       *string
+	dnsCacheSize         *int
+	dnsCachePersist      *bool
+	rdapTimeout          *time.Duration
+	rdapBootstrapURL     *string
+	rdapRate             *string
+	rdapServerRates      *string
+	rdapBreakerThreshold *int
+	rdapBreakerCooldown  *time.Duration
+	rdapAdaptive         *bool
+	rdapFloor            *time.Duration
+	rdapCeiling          *time.Duration
+	rdapDetails          *bool
+	whoisTimeout         *time.Duration
+	whoisRate            *string
+	whoisServerRates     *string
+}
+
+func addVerifierFlags(fs *flag.FlagSet, defaultCheckers string) *verifierFlags {
+	return &verifierFlags{
+		checkers:             fs.String("checkers", defaultCheckers, "comma separated checkers domains go through in order until one is definitive, any of zonefile, dns, rdap, whois or epp"),
+		dnsTimeout:           fs.Duration("dns-timeout", 30*time.Second, "timeout for a single DNS lookup"),
+		nameservers:          fs.String("nameservers", "", "comma separated nameservers, eg 1.1.1.1,8.8.8.8:53 or https://cloudflare-dns.com/dns-query over https, empty uses the nameservers in /etc/resolv.conf"),
+		dnsStrategy:          fs.String("dns-strategy", string(dnsresolver.StrategyRoundRobin), "how nameservers are picked, round-robin or failover"),
+		dnsTransport:         fs.String("dns-transport", string(dnsresolver.TransportUDP), "network DNS lookups are sent over, udp, tcp, tls or https"),
+		dnsMode:              fs.String("dns-mode", string(dnsresolver.ModeRecursive), "recursive, or authoritative to ask the TLD's nameservers directly"),
+		dnsIPv6:              fs.Bool("dns-ipv6", false, "in authoritative mode, also ask the TLD's nameservers over ipv6 when ipv4 fails"),
+		dnsHTTPSMethod:       fs.String("dns-https-method", "POST", "how DNS over https queries are sent, POST or GET"),
+		dnsCacheSize:         fs.Int("dns-cache-size", 100_000, "number of DNS answers cached in memory, 0 disables caching"),
+		dnsCachePersist:      fs.Bool("dns-cache-persist", false, "keep cached DNS answers in the database between runs"),
+		rdapTimeout:          fs.Duration("rdap-timeout", 10*time.Second, "timeout for a single RDAP request"),
+		rdapBootstrapURL:     fs.String("rdap-bootstrap-url", "", "where to download the RDAP bootstrap registry from instead of IANA, eg a mirror"),
+		rdapRate:             fs.String("rdap-rate", "15s/5", "requests allowed to each RDAP server as <interval>/<burst>, eg 15s/5 is one request every 15 seconds with bursts of 5"),
+		rdapServerRates:      fs.String("rdap-server-rates", "", "comma separated per server overrides of -rdap-rate, eg https://rdap.verisign.com/com/v1/=1s/10"),
+		rdapBreakerThreshold: fs.Int("rdap-breaker-threshold", 5, "failures in a row before an RDAP server is considered down and its domains are deferred"),
+		rdapBreakerCooldown:  fs.Duration("rdap-breaker-cooldown", time.Minute, "how long an RDAP server considered down is left alone before it's tried again"),
+		rdapAdaptive:         fs.Bool("rdap-adaptive", true, "adjust the rate of each RDAP server to how it responds, starting from -rdap-rate and remembering what was learned between runs"),
+		rdapFloor:            fs.Duration("rdap-floor", time.Minute, "slowest interval between requests to an RDAP server the adaptive rate can drop to"),
+		rdapCeiling:          fs.Duration("rdap-ceiling", 0, "fastest interval between requests to an RDAP server the adaptive rate can climb to, 0 is the fastest of -rdap-rate and -rdap-server-rates"),
+		rdapDetails:          fs.Bool("rdap-details", false, "also ask RDAP for the registration (status, expiry, registrar) of domains a checker before rdap found registered, eg zonefile or dns, so they can be watched; costs an RDAP request per registered domain"),
+		whoisTimeout:         fs.Duration("whois-timeout", 10*time.Second, "timeout for a single WHOIS query"),
+		whoisRate:            fs.String("whois-rate", "2s/2", "queries allowed to each WHOIS server as <interval>/<burst>, eg 2s/2 is one query every 2 seconds with bursts of 2"),
+		whoisServerRates:     fs.String("whois-server-rates", "", "comma separated per server overrides of -whois-rate, eg whois.nic.io=5s/1"),
+	}
+}
+
+/** newVerifier builds the verifydomain usecases with the checker chain the flags ask for. The returned func must be
+ * called once verification is done, it logs out of epp and persists what was learned along the way.
+ */
+func (f *verifierFlags) newVerifier(conn *sql.DB) (verifydomain.Usecases, func()) {
+	logger := logx.GetDefaultLogger()
+
+	domaincheckRepo := domaincheck.NewRepository(conn)
+	domainbanRepo := domainban.NewRepository(conn)
+	zonedomainRepo := zonedomain.NewRepository(conn)
+	dnscacheRepo := dnscache.NewRepository(conn)
+	rdaprateRepo := rdaprate.NewRepository(conn)
+	registrationRepo := registration.NewRepository(conn)
+
+	ua := getUserAgent()
+	rdapClient, err := rdapclient.New(rdapclient.Config{
+		UserAgent: ua,
+		HTTPClient: &http.Client{
+			Timeout: *f.rdapTimeout,
+		},
+		BootstrapURL: *f.rdapBootstrapURL,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	rdapConfig := verifydomain.RDAPConfig{
+		DefaultLimit: parseRDAPLimit(*f.rdapRate),
+		Limits:       parseRDAPLimits(*f.rdapServerRates),
+
+		BreakerThreshold: *f.rdapBreakerThreshold,
+		BreakerCooldown:  *f.rdapBreakerCooldown,
+	}
+	var rdapRates *verifydomain.RDAPRates
+	if *f.rdapAdaptive {
+		rdapRates = verifydomain.NewRDAPRates()
+		restoreRDAPRates(rdaprateRepo, rdapRates)
+		rdapConfig.Adaptive = &verifydomain.RDAPAdaptive{
+			Floor:   *f.rdapFloor,
+			Ceiling: *f.rdapCeiling,
+			Rates:   rdapRates,
+		}
+	}
+
+	whoisClient, err := whoisclient.New(whoisclient.Config{
+		Timeout:      *f.whoisTimeout,
+		DefaultLimit: parseWHOISLimit(*f.whoisRate),
+		Limits:       parseWHOISLimits(*f.whoisServerRates),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var dnsCache *dnsresolver.Cache
+	if *f.dnsCacheSize > 0 {
+		dnsCache = dnsresolver.NewCache(*f.dnsCacheSize)
+		if *f.dnsCachePersist {
+			restoreDNSCache(dnscacheRepo, dnsCache)
+		}
+	}
+
+	dnsResolver, err := dnsresolver.New(dnsresolver.Config{
+		Timeout:           *f.dnsTimeout,
+		Nameservers:       splitList(*f.nameservers),
+		Strategy:          dnsresolver.Strategy(*f.dnsStrategy),
+		Transport:         dnsresolver.Transport(*f.dnsTransport),
+		HTTPMethod:        *f.dnsHTTPSMethod,
+		Mode:              dnsresolver.Mode(*f.dnsMode),
+		AuthoritativeIPv6: *f.dnsIPv6,
+		Cache:             dnsCache,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// a single rdap checker serves both the chain and registration details so they share every server's budget
+	var rdapChecker verifydomain.Checker
+	newRDAPChecker := func() verifydomain.Checker {
+		if rdapChecker == nil {
+			rdapChecker = verifydomain.NewRDAPChecker(rdapClient, rdapConfig)
+		}
+		return rdapChecker
+	}
+
+	// the epp session is only opened if it's part of the chain, it needs registrar credentials
+	var eppClient *eppclient.Client
+	available := map[string]func() verifydomain.Checker{
+		"zonefile": func() verifydomain.Checker { return verifydomain.NewZoneFileChecker(zonedomainRepo) },
+		"dns":      func() verifydomain.Checker { return verifydomain.NewDNSChecker(dnsResolver) },
+		"rdap":     newRDAPChecker,
+		"whois":    func() verifydomain.Checker { return verifydomain.NewWHOISChecker(whoisClient) },
+		"epp": func() verifydomain.Checker {
+			eppClient = newEPPClient()
+			return verifydomain.NewEPPChecker(eppClient, eppTLDs())
+		},
+	}
+	var chain []verifydomain.Checker
+	for _, name := range splitList(*f.checkers) {
+		newChecker, ok := available[name]
+		if !ok {
+			panic(fmt.Sprintf("unknown checker %q", name))
+		}
+		chain = append(chain, newChecker())
+	}
+
+	var details verifydomain.Checker
+	if *f.rdapDetails {
+		details = newRDAPChecker()
+	}
+
+	verifydomainUsecase := verifydomain.New(
+		domaincheckRepo,
+		domainbanRepo,
+		registrationRepo,
+		details,
+		chain...,
+	)
+
+	done := func() {
+		if eppClient != nil {
+			if err := eppClient.Close(context.Background()); err != nil {
+				logger.Warn("failed to log out of epp", fields.Error(err))
+			}
+		}
+		if dnsCache != nil {
+			logDNSCacheStats(dnsCache)
+			if *f.dnsCachePersist {
+				persistDNSCache(dnscacheRepo, dnsCache)
+			}
+		}
+		if rdapRates != nil {
+			persistRDAPRates(rdaprateRepo, rdapRates)
+		}
+	}
+	return verifydomainUsecase, done
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/watch"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/usecases/watchdomain"
+)
+
+// runWatch handles `gather-cli watch [sync|run|list|events] [flags]`
+func runWatch(args []string) {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	expiringWithin := fs.Duration("expiring-within", 30*24*time.Hour, "watch registered domains expiring within this long, not just the ones already in redemption or pending delete")
+	syncEvery := fs.Duration("sync-every", time.Hour, "how often the watchlist picks up newly found registrations while running")
+	concurrency := fs.Int("concurrency", 4, "number of watched domains checked in parallel")
+	onEvent := fs.String("on-event", "", "shell command run for every event, with WATCH_DOMAIN and WATCH_EVENT set, eg to register a dropped domain")
+	since := fs.Duration("since", 7*24*time.Hour, "how far back events are listed")
+	// zone files lag behind by up to a day so they'd keep a dropped domain looking taken
+	verifier := addVerifierFlags(fs, "dns,rdap")
+	_ = fs.Parse(args)
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	watchRepo := watch.NewRepository(conn)
+	registrationRepo := registration.NewRepository(conn)
+
+	switch action {
+	case "sync":
+		watchdomainUsecase := watchdomain.New(watchRepo, registrationRepo, nil, *expiringWithin)
+		n, err := watchdomainUsecase.Sync(time.Now())
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("Synced watchlist", fields.Int("n", n))
+	case "run":
+		// rechecks go by the stored registration, which only stays current if rdap is asked for it every time even when
+		// an earlier checker settles the domain as registered
+		*verifier.rdapDetails = true
+		verifydomainUsecase, done := verifier.newVerifier(conn)
+		defer done()
+		watchdomainUsecase := watchdomain.New(watchRepo, registrationRepo, verifydomainUsecase, *expiringWithin)
+
+		// run until SIGINT/SIGTERM, whatever was being checked is simply due again next time
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := watchdomainUsecase.Run(*concurrency, *syncEvery, ctx, func(event watch.Event) {
+			emitWatchEvent(event, *onEvent)
+		})
+		if err != nil && ctx.Err() == nil {
+			panic(err)
+		}
+	case "list":
+		entries, err := watchRepo.GetEntries()
+		if err != nil {
+			panic(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DOMAIN\tREASON\tESTIMATED DROP\tNEXT CHECK\tCHECKS")
+		for _, e := range entries {
+			estimatedDropAt := ""
+			if e.EstimatedDropAt != nil {
+				estimatedDropAt = e.EstimatedDropAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", e.Domain, e.Reason, estimatedDropAt, e.NextCheckAt.UTC().Format(time.RFC3339), e.Checks)
+		}
+		w.Flush()
+	case "events":
+		events, err := watchRepo.GetEvents(time.Now().Add(-*since))
+		if err != nil {
+			panic(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "AT\tDOMAIN\tEVENT")
+		for _, e := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.At.UTC().Format(time.RFC3339), e.Domain, e.Kind)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown watch action %q, expected sync, run, list or events\n", action)
+		os.Exit(2)
+	}
+}
+
+// emitWatchEvent logs the event and hands it to the -on-event command if there is one
+func emitWatchEvent(event watch.Event, command string) {
+	logger := logx.GetDefaultLogger()
+
+	logger.Warn("Watched domain "+string(event.Kind),
+		fields.String("domain", event.Domain),
+		fields.TimeField("at", event.At),
+	)
+	if command == "" {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"WATCH_DOMAIN="+event.Domain,
+		"WATCH_EVENT="+string(event.Kind),
+	)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		logger.Error("on-event command failed",
+			fields.String("domain", event.Domain),
+			fields.Error(err),
+		)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/zonedomain"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/platform/zonefile"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+// runZone handles `gather-cli zone [load|status] [flags] [file]`
+func runZone(args []string) {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("zone", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	zone := fs.String("zone", "", "zone the file is for, eg net, defaults to the file name up to the first dot (as CZDS names them)")
+	chunkSize := fs.Int("chunk-size", zonedomain.DefaultLoadChunkSize, "number of domains committed per transaction while loading")
+	_ = fs.Parse(args)
+
+	logger := logx.GetDefaultLogger()
+	conn := openDatabase(*dbPath)
+	defer sqlite.CloseConnection(conn)
+
+	zonedomainRepo := zonedomain.NewRepository(conn)
+
+	switch action {
+	case "load":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: zone load [flags] <file>")
+			os.Exit(2)
+		}
+		path := fs.Arg(0)
+		if *zone == "" {
+			*zone, _, _ = strings.Cut(filepath.Base(path), ".")
+		}
+		*zone = strings.TrimSuffix(strings.ToLower(*zone), ".")
+
+		f, err := zonefile.Open(path)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		t := time.Now()
+		n, err := zonedomainRepo.LoadZone(*zone, zonefile.Delegations(f, *zone), *chunkSize, t)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info(
+			"Loaded zone",
+			fields.String("zone", *zone),
+			fields.Int("n", n),
+			fields.Duration("took", time.Since(t)),
+		)
+	case "status":
+		zones, err := zonedomainRepo.GetZones()
+		if err != nil {
+			panic(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ZONE\tDOMAINS\tLOADED AT")
+		for _, z := range zones {
+			loadedAt := ""
+			if z.LoadedAt != nil {
+				loadedAt = z.LoadedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", z.Zone, z.Domains, loadedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown zone action %q, expected load or status\n", action)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,79 @@
+package dnscache
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+type (
+	Repository struct {
+		conn *sql.DB
+	}
+
+	Entry struct {
+		Domain    string
+		Status    string
+		ExpiresAt time.Time
+	}
+)
+
+func NewRepository(conn *sql.DB) Repository {
+	return Repository{
+		conn: conn,
+	}
+}
+
+// GetUnexpiredEntries returns every entry still valid at now
+func (repo Repository) GetUnexpiredEntries(now time.Time) ([]Entry, error) {
+	rows, err := repo.conn.Query(
+		"SELECT domain, status, expires_at FROM dns_cache WHERE expires_at > ?;",
+		utils.ToSQLiteDT(&now),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Entry, 0)
+	for rows.Next() {
+		var result Entry
+		if err := rows.Scan(&result.Domain, &result.Status, &result.ExpiresAt); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ReplaceEntries swaps the persisted cache for entries, dropping whatever expired in the meantime
+func (repo Repository) ReplaceEntries(entries []Entry) error {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM dns_cache;"); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO dns_cache (domain, status, expires_at) VALUES (?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		if _, err := stmt.Exec(entry.Domain, entry.Status, utils.ToSQLiteDT(&entry.ExpiresAt)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package dnscache
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRepository(conn)
+}
+
+func TestReplaceEntries(t *testing.T) {
+	repo := newTestRepository(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+
+	err := repo.ReplaceEntries([]Entry{
+		{Domain: "old.test", Status: "delegated", ExpiresAt: now.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run's cache replaces the first's entirely
+	err = repo.ReplaceEntries([]Entry{
+		{Domain: "a.test", Status: "delegated", ExpiresAt: now.Add(time.Hour)},
+		{Domain: "b.test", Status: "nxdomain", ExpiresAt: now.Add(time.Second)},
+		{Domain: "expired.test", Status: "exists", ExpiresAt: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetUnexpiredEntries(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(got, func(a, b Entry) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+
+	want := []Entry{
+		{Domain: "b.test", Status: "nxdomain", ExpiresAt: now.Add(time.Second)},
+		{Domain: "a.test", Status: "delegated", ExpiresAt: now.Add(time.Hour)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetUnexpiredEntries() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Domain != want[i].Domain || got[i].Status != want[i].Status || !got[i].ExpiresAt.Equal(want[i].ExpiresAt) {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package domainban
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/khinshankhan/nomex/utils"
@@ -13,12 +14,34 @@ type (
 	}
 
 	DomainBan struct {
-		Domain string
-		Reason *string
-		At     *time.Time
+		Domain    string
+		Reason    *string
+		At        *time.Time
+		Category  Category
+		ExpiresAt *time.Time // nil for bans which never expire
+		Strikes   int        // number of times the domain has been banned in a row, drives the cooldown
 	}
+
+	// Category is whether a ban is meant to be lifted eventually
+	Category string
+)
+
+const (
+	CategoryPermanent Category = "permanent"
+	CategoryTransient Category = "transient"
 )
 
+const domainBanColumns = "domain, reason, ban_at, category, expires_at, strikes"
+
+// Cooldown is base doubled for every strike after the first, capped at max.
+func Cooldown(strikes int, base, max time.Duration) time.Duration {
+	cooldown := base
+	for i := 1; i < strikes && cooldown < max; i++ {
+		cooldown *= 2
+	}
+	return min(cooldown, max)
+}
+
 func NewRepository(conn *sql.DB) Repository {
 	return Repository{
 		conn: conn,
@@ -26,21 +49,121 @@ func NewRepository(conn *sql.DB) Repository {
 }
 
 func (repo Repository) BanDomain(ban DomainBan) error {
+	if ban.Category == "" {
+		ban.Category = CategoryPermanent
+	}
+
 	_, err := repo.conn.Exec(
-		"INSERT OR REPLACE INTO banned (domain, reason, ban_at) VALUES (?, ?, ?);",
+		"INSERT OR REPLACE INTO banned ("+domainBanColumns+") VALUES (?, ?, ?, ?, ?, ?);",
+		ban.Domain,
+		ban.Reason,
+		utils.ToSQLiteDT(ban.At),
+		ban.Category,
+		utils.ToSQLiteDT(ban.ExpiresAt),
+		ban.Strikes,
+	)
+
+	return err
+}
+
+/** StrikeDomain bans a domain transiently for a cooldown that grows with every consecutive strike, see Cooldown.
+ * Permanent bans are left untouched.
+ */
+func (repo Repository) StrikeDomain(domain string, reason string, at time.Time, base, max time.Duration) (DomainBan, error) {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return DomainBan{}, err
+	}
+
+	var category Category
+	var strikes int
+	err = tx.QueryRow("SELECT category, strikes FROM banned WHERE domain = ?;", domain).Scan(&category, &strikes)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return DomainBan{}, err
+	}
+	if category == CategoryPermanent {
+		_ = tx.Rollback()
+		return DomainBan{}, nil
+	}
+
+	expiresAt := at.Add(Cooldown(strikes+1, base, max))
+	ban := DomainBan{
+		Domain:    domain,
+		Reason:    &reason,
+		At:        &at,
+		Category:  CategoryTransient,
+		ExpiresAt: &expiresAt,
+		Strikes:   strikes + 1,
+	}
+	_, err = tx.Exec(
+		"INSERT OR REPLACE INTO banned ("+domainBanColumns+") VALUES (?, ?, ?, ?, ?, ?);",
 		ban.Domain,
 		ban.Reason,
 		utils.ToSQLiteDT(ban.At),
+		ban.Category,
+		utils.ToSQLiteDT(ban.ExpiresAt),
+		ban.Strikes,
 	)
+	if err != nil {
+		_ = tx.Rollback()
+		return DomainBan{}, err
+	}
+
+	return ban, tx.Commit()
+}
+
+/** DeferDomain bans a domain transiently until the given time without counting it as a strike, for when the domain
+ * couldn't be checked through no fault of its own, eg its rdap server is down. Strikes already counted are kept, as is
+ * a ban which lasts longer, and permanent bans are left untouched.
+ */
+func (repo Repository) DeferDomain(domain string, reason string, at time.Time, until time.Time) error {
+	_, err := repo.conn.Exec(
+		"INSERT INTO banned ("+domainBanColumns+") VALUES (?, ?, ?, ?, ?, 0)"+`
+		ON CONFLICT(domain) DO UPDATE SET
+			reason = excluded.reason,
+			ban_at = excluded.ban_at,
+			expires_at = COALESCE(MAX(banned.expires_at, excluded.expires_at), excluded.expires_at)
+		WHERE banned.category = ?;`,
+		domain,
+		reason,
+		utils.ToSQLiteDT(&at),
+		CategoryTransient,
+		utils.ToSQLiteDT(&until),
+		CategoryTransient,
+	)
+	return err
+}
 
+// ClearTransientBan forgets a domain's transient ban and strikes, eg once it has been checked successfully
+func (repo Repository) ClearTransientBan(domain string) error {
+	_, err := repo.conn.Exec("DELETE FROM banned WHERE domain = ? AND category = ?;", domain, CategoryTransient)
 	return err
 }
 
+// UnbanDomain lifts a ban, returns false if the domain wasn't banned
+func (repo Repository) UnbanDomain(domain string) (bool, error) {
+	res, err := repo.conn.Exec("DELETE FROM banned WHERE domain = ?;", domain)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 func unpackDomainBanRows(rows *sql.Rows) ([]DomainBan, error) {
 	results := make([]DomainBan, 0)
 	for rows.Next() {
 		var result DomainBan
-		err := rows.Scan(&result.Domain, &result.Reason, &result.At)
+		err := rows.Scan(
+			&result.Domain,
+			&result.Reason,
+			&result.At,
+			&result.Category,
+			&result.ExpiresAt,
+			&result.Strikes,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -55,7 +178,23 @@ func unpackDomainBanRows(rows *sql.Rows) ([]DomainBan, error) {
 }
 
 func (repo Repository) GetAllBannedDomains() ([]DomainBan, error) {
-	rows, err := repo.conn.Query("SELECT domain, reason, ban_at FROM banned;")
+	rows, err := repo.conn.Query("SELECT " + domainBanColumns + " FROM banned;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results, err := unpackDomainBanRows(rows)
+	return results, err
+}
+
+// GetActiveBans returns every ban still in effect at now, expired transient bans are left out so their domains go
+// back to being checked.
+func (repo Repository) GetActiveBans(now time.Time) ([]DomainBan, error) {
+	rows, err := repo.conn.Query(
+		"SELECT "+domainBanColumns+" FROM banned WHERE expires_at IS NULL OR expires_at > ?;",
+		utils.ToSQLiteDT(&now),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,32 @@
+package domaincheck
+
+// Availability is the verdict of a domain check
+type Availability string
+
+const (
+	AvailabilityRegistered Availability = "registered"
+	AvailabilityAvailable  Availability = "available"
+	AvailabilityReserved   Availability = "reserved" // held by the registry, can't be registered normally
+	AvailabilityUnknown    Availability = "unknown"  // checked but no source could give an answer (eg no RDAP for tld)
+	AvailabilityError      Availability = "error"    // the check failed, see ErrorClass
+)
+
+// Definitive reports whether rechecking is pointless, ie the verdict came from a source we trust.
+func (a Availability) Definitive() bool {
+	return a == AvailabilityRegistered || a == AvailabilityAvailable || a == AvailabilityReserved
+}
+
+// ErrorClass describes why a check didn't produce a definitive answer
+type ErrorClass string
+
+const (
+	ErrorClassTimeout     ErrorClass = "timeout"
+	ErrorClassCanceled    ErrorClass = "canceled"
+	ErrorClassRateLimited ErrorClass = "rate_limited"
+	ErrorClassDNS         ErrorClass = "dns"
+	ErrorClassUnsupported ErrorClass = "unsupported" // eg no RDAP server for the tld
+	ErrorClassUpstream    ErrorClass = "upstream"    // the server misbehaved
+	ErrorClassUnavailable ErrorClass = "unavailable" // couldn't reach the server
+	ErrorClassInput       ErrorClass = "input"
+	ErrorClassInternal    ErrorClass = "internal"
+)
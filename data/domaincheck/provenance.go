@@ -0,0 +1,12 @@
+package domaincheck
+
+// Source is the backend whose answer was taken as the result of a check
+type Source string
+
+const (
+	SourceZoneFile Source = "zonefile"
+	SourceDNS      Source = "dns"
+	SourceRDAP     Source = "rdap"
+	SourceWHOIS    Source = "whois"
+	SourceEPP      Source = "epp"
+)
@@ -2,6 +2,8 @@ package domaincheck
 
 import (
 	"database/sql"
+	"iter"
+	"slices"
 	"time"
 
 	"github.com/khinshankhan/nomex/utils"
@@ -13,37 +15,89 @@ type (
 	}
 
 	DomainCheck struct {
-		Domain string
-		Code   *int
-		At     *time.Time
+		Domain       string
+		Availability *Availability // nil until the domain has been checked
+		ErrorClass   *ErrorClass   // set when Availability is error or unknown
+		At           *time.Time
+
+		// provenance, ie how the result was reached
+		Source     *Source
+		Attempts   *int           // queries made to Source
+		Latency    *time.Duration // end to end time of the check
+		Error      *string        // final error, if any
+		RDAPServer *string        // base url of the rdap server that was asked, if any
 	}
 )
 
+const domainCheckColumns = "domain, availability, error_class, checked_at, source, attempts, latency_ms, error, rdap_server"
+
 func NewRepository(conn *sql.DB) Repository {
 	return Repository{
 		conn: conn,
 	}
 }
 
+// SaveDomainCheck records check as the latest state of the domain and appends it to the domain's history.
 func (repo Repository) SaveDomainCheck(check DomainCheck) error {
-	_, err := repo.conn.Exec(
-		"INSERT OR REPLACE INTO checks (domain, code, checked_at) VALUES (?, ?, ?);",
-		check.Domain,
-		check.Code,
-		utils.ToSQLiteDT(check.At),
-	)
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
 
-	return err
+	var latencyMs *int64
+	if check.Latency != nil {
+		ms := check.Latency.Milliseconds()
+		latencyMs = &ms
+	}
+
+	for _, query := range []string{
+		"INSERT OR REPLACE INTO checks (" + domainCheckColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);",
+		"INSERT INTO check_history (" + domainCheckColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);",
+	} {
+		_, err = tx.Exec(
+			query,
+			check.Domain,
+			check.Availability,
+			check.ErrorClass,
+			utils.ToSQLiteDT(check.At),
+			check.Source,
+			check.Attempts,
+			latencyMs,
+			check.Error,
+			check.RDAPServer,
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func unpackDomainCheckRows(rows *sql.Rows) ([]DomainCheck, error) {
 	results := make([]DomainCheck, 0)
 	for rows.Next() {
 		var result DomainCheck
-		err := rows.Scan(&result.Domain, &result.Code, &result.At)
+		var latencyMs *int64
+		err := rows.Scan(
+			&result.Domain,
+			&result.Availability,
+			&result.ErrorClass,
+			&result.At,
+			&result.Source,
+			&result.Attempts,
+			&latencyMs,
+			&result.Error,
+			&result.RDAPServer,
+		)
 		if err != nil {
 			return nil, err
 		}
+		if latencyMs != nil {
+			latency := time.Duration(*latencyMs) * time.Millisecond
+			result.Latency = &latency
+		}
 
 		results = append(results, result)
 	}
@@ -55,7 +109,7 @@ func unpackDomainCheckRows(rows *sql.Rows) ([]DomainCheck, error) {
 }
 
 func (repo Repository) GetAllCheckedDomains() ([]DomainCheck, error) {
-	rows, err := repo.conn.Query("SELECT domain, code, checked_at FROM checks;")
+	rows, err := repo.conn.Query("SELECT " + domainCheckColumns + " FROM checks;")
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +120,12 @@ func (repo Repository) GetAllCheckedDomains() ([]DomainCheck, error) {
 }
 
 func (repo Repository) GetPendingDomains() ([]DomainCheck, error) {
-	rows, err := repo.conn.Query("SELECT domain, code, checked_at FROM checks WHERE code IS NULL OR code NOT IN (200,404) ORDER BY domain ASC;")
+	rows, err := repo.conn.Query(
+		"SELECT "+domainCheckColumns+" FROM checks WHERE availability IS NULL OR availability NOT IN (?, ?, ?) ORDER BY domain ASC;",
+		AvailabilityRegistered,
+		AvailabilityAvailable,
+		AvailabilityReserved,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -76,8 +135,57 @@ func (repo Repository) GetPendingDomains() ([]DomainCheck, error) {
 	return results, err
 }
 
+// DefaultPendingPageSize is how many pending domains are read per query when streaming
+const DefaultPendingPageSize = 10_000
+
+/** StreamPendingDomains yields every pending domain in order, reading pageSize rows per query. Each page is its own
+ * short query rather than one long running cursor, so results can be saved while the stream is being consumed.
+ */
+func (repo Repository) StreamPendingDomains(pageSize int) iter.Seq2[DomainCheck, error] {
+	if pageSize <= 0 {
+		pageSize = DefaultPendingPageSize
+	}
+
+	return func(yield func(DomainCheck, error) bool) {
+		after := ""
+		for {
+			rows, err := repo.conn.Query(
+				"SELECT "+domainCheckColumns+" FROM checks WHERE (availability IS NULL OR availability NOT IN (?, ?, ?)) AND domain > ? ORDER BY domain ASC LIMIT ?;",
+				AvailabilityRegistered,
+				AvailabilityAvailable,
+				AvailabilityReserved,
+				after,
+				pageSize,
+			)
+			if err != nil {
+				yield(DomainCheck{}, err)
+				return
+			}
+			page, err := unpackDomainCheckRows(rows)
+			rows.Close()
+			if err != nil {
+				yield(DomainCheck{}, err)
+				return
+			}
+
+			for _, check := range page {
+				if !yield(check, nil) {
+					return
+				}
+			}
+			if len(page) < pageSize {
+				return
+			}
+			after = page[len(page)-1].Domain
+		}
+	}
+}
+
 func (repo Repository) GetAvailableDomains() ([]DomainCheck, error) {
-	rows, err := repo.conn.Query("SELECT domain, code, checked_at FROM checks WHERE code = 404 ORDER BY domain ASC;")
+	rows, err := repo.conn.Query(
+		"SELECT "+domainCheckColumns+" FROM checks WHERE availability = ? ORDER BY domain ASC;",
+		AvailabilityAvailable,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -87,23 +195,172 @@ func (repo Repository) GetAvailableDomains() ([]DomainCheck, error) {
 	return results, err
 }
 
+// DefaultEnsureChunkSize is how many domains are committed per transaction when streaming
+const DefaultEnsureChunkSize = 10_000
+
 func (repo Repository) BulkEnsureDomainChecks(domains []string) error {
-	tx, err := repo.conn.Begin()
-	if err != nil {
+	_, err := repo.StreamEnsureDomainChecks(slices.Values(domains), DefaultEnsureChunkSize)
+	return err
+}
+
+// StreamEnsureDomainChecks drains domains into checks committing every chunkSize rows, so memory stays bounded
+// regardless of how large the stream is. Returns the number of domains committed, chunks committed before an error are
+// kept.
+func (repo Repository) StreamEnsureDomainChecks(domains iter.Seq[string], chunkSize int) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultEnsureChunkSize
+	}
+
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+	begin := func() error {
+		var err error
+		tx, err = repo.conn.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err = tx.Prepare(`INSERT OR IGNORE INTO checks(domain) VALUES(?)`)
+		if err != nil {
+			_ = tx.Rollback()
+			tx = nil
+			return err
+		}
+		return nil
+	}
+	commit := func() error {
+		_ = stmt.Close()
+		err := tx.Commit()
+		tx, stmt = nil, nil
 		return err
 	}
-	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO checks(domain) VALUES(?)`)
-	if err != nil {
+	rollback := func() {
+		_ = stmt.Close()
 		_ = tx.Rollback()
-		return err
+		tx, stmt = nil, nil
 	}
-	defer stmt.Close()
 
-	for _, d := range domains {
+	total, pending := 0, 0
+	for d := range domains {
+		if tx == nil {
+			if err := begin(); err != nil {
+				return total, err
+			}
+		}
+
 		if _, err := stmt.Exec(d); err != nil {
-			_ = tx.Rollback()
-			return err
+			rollback()
+			return total - pending, err
+		}
+		total++
+		pending++
+
+		if pending >= chunkSize {
+			if err := commit(); err != nil {
+				return total - pending, err
+			}
+			pending = 0
 		}
 	}
-	return tx.Commit()
+
+	if tx != nil {
+		if err := commit(); err != nil {
+			return total - pending, err
+		}
+	}
+	return total, nil
+}
+
+type AvailabilityCount struct {
+	Availability *Availability // nil for domains which haven't been checked yet
+	ErrorClass   *ErrorClass
+	N            int
+}
+
+func (repo Repository) GetAvailabilityCounts() ([]AvailabilityCount, error) {
+	rows, err := repo.conn.Query("SELECT availability, error_class, COUNT(*) FROM checks GROUP BY availability, error_class ORDER BY availability ASC, error_class ASC;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]AvailabilityCount, 0)
+	for rows.Next() {
+		var result AvailabilityCount
+		if err := rows.Scan(&result.Availability, &result.ErrorClass, &result.N); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetDomainTimeline returns every recorded check of domain, oldest first.
+func (repo Repository) GetDomainTimeline(domain string) ([]DomainCheck, error) {
+	rows, err := repo.conn.Query(
+		"SELECT "+domainCheckColumns+" FROM check_history WHERE domain = ? ORDER BY id ASC;",
+		domain,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results, err := unpackDomainCheckRows(rows)
+	return results, err
+}
+
+type Transition struct {
+	Domain string
+	From   Availability
+	To     Availability
+	At     *time.Time // when the new state was observed
+}
+
+/** GetTransitions returns every change between definitive states (eg registered -> available) observed in [from, to),
+ * oldest first.
+ *
+ * NOTE: errors and unknowns in between are skipped, so registered -> error -> registered isn't a transition.
+ */
+func (repo Repository) GetTransitions(from, to time.Time) ([]Transition, error) {
+	rows, err := repo.conn.Query(
+		`SELECT domain, previous, availability, checked_at
+		FROM (
+			SELECT
+				domain,
+				availability,
+				checked_at,
+				LAG(availability) OVER (PARTITION BY domain ORDER BY id) AS previous
+			FROM check_history
+			WHERE availability IN (?, ?, ?)
+		)
+		WHERE previous IS NOT NULL AND previous != availability AND checked_at >= ? AND checked_at < ?
+		ORDER BY checked_at ASC;`,
+		AvailabilityRegistered,
+		AvailabilityAvailable,
+		AvailabilityReserved,
+		utils.ToSQLiteDT(&from),
+		utils.ToSQLiteDT(&to),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Transition, 0)
+	for rows.Next() {
+		var result Transition
+		if err := rows.Scan(&result.Domain, &result.From, &result.To, &result.At); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
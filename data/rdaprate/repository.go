@@ -0,0 +1,74 @@
+package rdaprate
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+type (
+	Repository struct {
+		conn *sql.DB
+	}
+
+	Rate struct {
+		Server    string
+		Interval  time.Duration // time between requests
+		UpdatedAt time.Time
+	}
+)
+
+func NewRepository(conn *sql.DB) Repository {
+	return Repository{
+		conn: conn,
+	}
+}
+
+// GetRates returns the last known rate of every rdap server
+func (repo Repository) GetRates() ([]Rate, error) {
+	rows, err := repo.conn.Query("SELECT server, interval_ms, updated_at FROM rdap_rates;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Rate, 0)
+	for rows.Next() {
+		var result Rate
+		var intervalMs int64
+		if err := rows.Scan(&result.Server, &intervalMs, &result.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result.Interval = time.Duration(intervalMs) * time.Millisecond
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SaveRates upserts rates, servers which weren't asked this time keep what they had
+func (repo Repository) SaveRates(rates []Rate) error {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO rdap_rates (server, interval_ms, updated_at) VALUES (?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		if _, err := stmt.Exec(rate.Server, rate.Interval.Milliseconds(), utils.ToSQLiteDT(&rate.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package rdaprate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRepository(conn)
+}
+
+func TestSaveRates(t *testing.T) {
+	repo := newTestRepository(t)
+	first := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	err := repo.SaveRates([]Rate{
+		{Server: "https://rdap.a.example/", Interval: 15 * time.Second, UpdatedAt: first},
+		{Server: "https://rdap.b.example/", Interval: 2 * time.Second, UpdatedAt: first},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the next run only learned about a, b keeps what it had. Intervals are kept to the millisecond.
+	err = repo.SaveRates([]Rate{
+		{Server: "https://rdap.a.example/", Interval: 7500*time.Millisecond + 400*time.Microsecond, UpdatedAt: second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(got, func(a, b Rate) int { return strings.Compare(a.Server, b.Server) })
+
+	want := []Rate{
+		{Server: "https://rdap.a.example/", Interval: 7500 * time.Millisecond, UpdatedAt: second},
+		{Server: "https://rdap.b.example/", Interval: 2 * time.Second, UpdatedAt: first},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetRates() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Server != want[i].Server || got[i].Interval != want[i].Interval || !got[i].UpdatedAt.Equal(want[i].UpdatedAt) {
+			t.Errorf("rate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
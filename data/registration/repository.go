@@ -0,0 +1,182 @@
+package registration
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+type (
+	Repository struct {
+		conn *sql.DB
+	}
+
+	Registration struct {
+		Domain   string
+		Statuses []string // epp statuses, eg clientHold, redemptionPeriod or pendingDelete
+
+		RegisteredAt  *time.Time
+		ExpiresAt     *time.Time
+		LastChangedAt *time.Time
+
+		Registrar       *string
+		RegistrarIANAID *string
+
+		Nameservers []string
+		CheckedAt   time.Time
+	}
+)
+
+// statuses a registration goes through right before the domain is deleted and released
+const (
+	StatusRedemptionPeriod = "redemptionPeriod"
+	StatusPendingDelete    = "pendingDelete"
+)
+
+const registrationColumns = "domain, registered_at, expires_at, last_changed_at, registrar, registrar_iana_id, nameservers, checked_at"
+
+func NewRepository(conn *sql.DB) Repository {
+	return Repository{
+		conn: conn,
+	}
+}
+
+// SaveRegistration replaces whatever was known about the domain's registration
+func (repo Repository) SaveRegistration(reg Registration) error {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT OR REPLACE INTO registrations ("+registrationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?);",
+		reg.Domain,
+		utils.ToSQLiteDT(reg.RegisteredAt),
+		utils.ToSQLiteDT(reg.ExpiresAt),
+		utils.ToSQLiteDT(reg.LastChangedAt),
+		reg.Registrar,
+		reg.RegistrarIANAID,
+		strings.Join(reg.Nameservers, ","),
+		utils.ToSQLiteDT(&reg.CheckedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM registration_statuses WHERE domain = ?;", reg.Domain); err != nil {
+		return err
+	}
+	for _, status := range reg.Statuses {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO registration_statuses (domain, status) VALUES (?, ?);",
+			reg.Domain,
+			status,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRegistration forgets the domain's registration, eg once it's been released
+func (repo Repository) DeleteRegistration(domain string) error {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM registration_statuses WHERE domain = ?;", domain); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM registrations WHERE domain = ?;", domain); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanRegistration scans a row selected with registrationColumns followed by the comma separated statuses
+func scanRegistration(scan func(dest ...any) error) (Registration, error) {
+	var reg Registration
+	var nameservers, statuses string
+	err := scan(
+		&reg.Domain,
+		&reg.RegisteredAt,
+		&reg.ExpiresAt,
+		&reg.LastChangedAt,
+		&reg.Registrar,
+		&reg.RegistrarIANAID,
+		&nameservers,
+		&reg.CheckedAt,
+		&statuses,
+	)
+	if err != nil {
+		return Registration{}, err
+	}
+	if nameservers != "" {
+		reg.Nameservers = strings.Split(nameservers, ",")
+	}
+	if statuses != "" {
+		reg.Statuses = strings.Split(statuses, ",")
+	}
+	return reg, nil
+}
+
+const selectRegistrations = `
+	SELECT ` + registrationColumns + `,
+		COALESCE((SELECT group_concat(status) FROM registration_statuses s WHERE s.domain = r.domain), '')
+	FROM registrations r`
+
+// GetRegistration returns the domain's registration, nil if there isn't one
+func (repo Repository) GetRegistration(domain string) (*Registration, error) {
+	reg, err := scanRegistration(
+		repo.conn.QueryRow(selectRegistrations+" WHERE domain = ?;", domain).Scan,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+/** GetDropCandidates returns registrations which look like they're on their way to being deleted, ie in the redemption
+ * period, pending delete, or expiring before the given time.
+ */
+func (repo Repository) GetDropCandidates(expiringBefore time.Time) ([]Registration, error) {
+	rows, err := repo.conn.Query(
+		selectRegistrations+`
+		WHERE expires_at <= ?
+			OR domain IN (SELECT domain FROM registration_statuses WHERE status IN (?, ?))
+		ORDER BY domain;`,
+		utils.ToSQLiteDT(&expiringBefore),
+		StatusRedemptionPeriod,
+		StatusPendingDelete,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Registration, 0)
+	for rows.Next() {
+		reg, err := scanRegistration(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, reg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
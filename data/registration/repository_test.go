@@ -0,0 +1,123 @@
+package registration
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRepository(conn)
+}
+
+func TestSaveRegistration(t *testing.T) {
+	repo := newTestRepository(t)
+	registeredAt := time.Date(1997, 9, 15, 4, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 9, 14, 4, 0, 0, 0, time.UTC)
+	checkedAt := time.Date(2026, 10, 16, 8, 0, 0, 500, time.UTC)
+	registrar, ianaID := "Example Registrar, Inc.", "292"
+
+	saved := Registration{
+		Domain:          "example.test",
+		Statuses:        []string{"clientHold", StatusRedemptionPeriod},
+		RegisteredAt:    &registeredAt,
+		ExpiresAt:       &expiresAt,
+		Registrar:       &registrar,
+		RegistrarIANAID: &ianaID,
+		Nameservers:     []string{"ns1.example.net", "ns2.example.net"},
+		CheckedAt:       checkedAt,
+	}
+	if err := repo.SaveRegistration(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetRegistration("example.test")
+	if err != nil || got == nil {
+		t.Fatalf("GetRegistration() = %+v, %v, want a registration", got, err)
+	}
+	slices.Sort(got.Statuses)
+	if !slices.Equal(got.Statuses, saved.Statuses) {
+		t.Errorf("statuses = %v, want %v", got.Statuses, saved.Statuses)
+	}
+	if got.RegisteredAt == nil || !got.RegisteredAt.Equal(registeredAt) || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("registered at %v expiring at %v, want %v and %v", got.RegisteredAt, got.ExpiresAt, registeredAt, expiresAt)
+	}
+	if got.LastChangedAt != nil {
+		t.Errorf("last changed at = %v, want nil", got.LastChangedAt)
+	}
+	if got.Registrar == nil || *got.Registrar != registrar || got.RegistrarIANAID == nil || *got.RegistrarIANAID != ianaID {
+		t.Errorf("registrar = %v (%v), want %q (%q)", got.Registrar, got.RegistrarIANAID, registrar, ianaID)
+	}
+	if !slices.Equal(got.Nameservers, saved.Nameservers) || !got.CheckedAt.Equal(checkedAt) {
+		t.Errorf("nameservers %v checked at %v, want %v at %v", got.Nameservers, got.CheckedAt, saved.Nameservers, checkedAt)
+	}
+
+	// the next answer replaces the last one, statuses included
+	renewed := Registration{Domain: "example.test", Statuses: []string{"ok"}, CheckedAt: checkedAt.Add(time.Hour)}
+	if err := repo.SaveRegistration(renewed); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetRegistration("example.test")
+	if err != nil || got == nil {
+		t.Fatalf("GetRegistration() = %+v, %v, want a registration", got, err)
+	}
+	if !slices.Equal(got.Statuses, []string{"ok"}) || got.ExpiresAt != nil || got.Registrar != nil || got.Nameservers != nil {
+		t.Errorf("GetRegistration() after replacing = %+v, want %+v", got, renewed)
+	}
+
+	if got, err := repo.GetRegistration("missing.test"); err != nil || got != nil {
+		t.Errorf("GetRegistration(missing) = %+v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestGetDropCandidates(t *testing.T) {
+	repo := newTestRepository(t)
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, reg := range []Registration{
+		{Domain: "redemption.test", Statuses: []string{StatusRedemptionPeriod}, ExpiresAt: at(365 * 24 * time.Hour)},
+		{Domain: "pending.test", Statuses: []string{"serverHold", StatusPendingDelete}},
+		{Domain: "expiring.test", Statuses: []string{"ok"}, ExpiresAt: at(24 * time.Hour)},
+		{Domain: "renewed.test", Statuses: []string{"ok"}, ExpiresAt: at(365 * 24 * time.Hour)},
+		{Domain: "unknown.test", Statuses: []string{"ok"}},
+		{Domain: "restored.test", Statuses: []string{StatusRedemptionPeriod}},
+		{Domain: "released.test", Statuses: []string{StatusPendingDelete}},
+	} {
+		reg.CheckedAt = now
+		if err := repo.SaveRegistration(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// restored out of redemption, and released for good
+	if err := repo.SaveRegistration(Registration{Domain: "restored.test", Statuses: []string{"ok"}, CheckedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteRegistration("released.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := repo.GetDropCandidates(now.Add(7 * 24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, reg := range candidates {
+		got = append(got, reg.Domain)
+	}
+	if want := []string{"expiring.test", "pending.test", "redemption.test"}; !slices.Equal(got, want) {
+		t.Errorf("GetDropCandidates() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package watch
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+type (
+	Repository struct {
+		conn *sql.DB
+	}
+
+	Entry struct {
+		Domain          string
+		Reason          Reason
+		EstimatedDropAt *time.Time // nil if there's nothing to go on
+		NextCheckAt     time.Time
+		LastCheckedAt   *time.Time
+		Checks          int
+		AddedAt         time.Time
+	}
+
+	// Reason is why a domain is expected to drop
+	Reason string
+
+	Event struct {
+		Domain string
+		Kind   EventKind
+		At     time.Time
+	}
+
+	EventKind string
+)
+
+const (
+	ReasonRedemptionPeriod Reason = "redemptionPeriod"
+	ReasonPendingDelete    Reason = "pendingDelete"
+	ReasonExpiring         Reason = "expiring"
+)
+
+const (
+	// EventDropped means the domain was deleted and can be registered
+	EventDropped EventKind = "dropped"
+	// EventRenewed means the domain was renewed or restored and isn't expected to drop anymore
+	EventRenewed EventKind = "renewed"
+)
+
+const watchColumns = "domain, reason, estimated_drop_at, next_check_at, last_checked_at, checks, added_at"
+
+func NewRepository(conn *sql.DB) Repository {
+	return Repository{
+		conn: conn,
+	}
+}
+
+/** WatchDomain adds the domain to the watchlist, or updates the reason and estimate if it's already there. A domain
+ * already on the watchlist keeps its next check if that's sooner.
+ */
+func (repo Repository) WatchDomain(entry Entry) error {
+	_, err := repo.conn.Exec(
+		"INSERT INTO watch ("+watchColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"+`
+		ON CONFLICT(domain) DO UPDATE SET
+			reason = excluded.reason,
+			estimated_drop_at = excluded.estimated_drop_at,
+			next_check_at = MIN(watch.next_check_at, excluded.next_check_at);`,
+		entry.Domain,
+		entry.Reason,
+		utils.ToSQLiteDT(entry.EstimatedDropAt),
+		utils.ToSQLiteDT(&entry.NextCheckAt),
+		utils.ToSQLiteDT(entry.LastCheckedAt),
+		entry.Checks,
+		utils.ToSQLiteDT(&entry.AddedAt),
+	)
+	return err
+}
+
+// SaveEntry overwrites the domain's entry, eg after a check
+func (repo Repository) SaveEntry(entry Entry) error {
+	_, err := repo.conn.Exec(
+		"INSERT OR REPLACE INTO watch ("+watchColumns+") VALUES (?, ?, ?, ?, ?, ?, ?);",
+		entry.Domain,
+		entry.Reason,
+		utils.ToSQLiteDT(entry.EstimatedDropAt),
+		utils.ToSQLiteDT(&entry.NextCheckAt),
+		utils.ToSQLiteDT(entry.LastCheckedAt),
+		entry.Checks,
+		utils.ToSQLiteDT(&entry.AddedAt),
+	)
+	return err
+}
+
+// UnwatchDomain takes the domain off the watchlist and records why
+func (repo Repository) UnwatchDomain(event Event) error {
+	tx, err := repo.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM watch WHERE domain = ?;", event.Domain); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO watch_events (domain, kind, at) VALUES (?, ?, ?);",
+		event.Domain,
+		event.Kind,
+		utils.ToSQLiteDT(&event.At),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo Repository) queryEntries(query string, args ...any) ([]Entry, error) {
+	rows, err := repo.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Entry, 0)
+	for rows.Next() {
+		var result Entry
+		err := rows.Scan(
+			&result.Domain,
+			&result.Reason,
+			&result.EstimatedDropAt,
+			&result.NextCheckAt,
+			&result.LastCheckedAt,
+			&result.Checks,
+			&result.AddedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetEntries returns the whole watchlist, soonest check first
+func (repo Repository) GetEntries() ([]Entry, error) {
+	return repo.queryEntries("SELECT " + watchColumns + " FROM watch ORDER BY next_check_at ASC;")
+}
+
+// GetDueEntries returns the entries due for a check at now, most overdue first
+func (repo Repository) GetDueEntries(now time.Time) ([]Entry, error) {
+	return repo.queryEntries(
+		"SELECT "+watchColumns+" FROM watch WHERE next_check_at <= ? ORDER BY next_check_at ASC;",
+		utils.ToSQLiteDT(&now),
+	)
+}
+
+// GetNextCheckAt returns when the next check is due, nil if the watchlist is empty
+func (repo Repository) GetNextCheckAt() (*time.Time, error) {
+	var next time.Time
+	err := repo.conn.QueryRow("SELECT next_check_at FROM watch ORDER BY next_check_at ASC LIMIT 1;").Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// GetEvents returns events since the given time, oldest first
+func (repo Repository) GetEvents(since time.Time) ([]Event, error) {
+	rows, err := repo.conn.Query(
+		"SELECT domain, kind, at FROM watch_events WHERE at >= ? ORDER BY at ASC, id ASC;",
+		utils.ToSQLiteDT(&since),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]Event, 0)
+	for rows.Next() {
+		var result Event
+		if err := rows.Scan(&result.Domain, &result.Kind, &result.At); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRepository(conn)
+}
+
+func TestWatchDomainKeepsTheSoonerCheck(t *testing.T) {
+	repo := newTestRepository(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	steps := []struct {
+		name      string
+		entry     Entry
+		wantCheck time.Time
+	}{
+		{
+			name:      "added",
+			entry:     Entry{Reason: ReasonExpiring, EstimatedDropAt: at(80 * 24 * time.Hour), NextCheckAt: now.Add(time.Hour)},
+			wantCheck: now.Add(time.Hour),
+		},
+		{
+			// eg a sync after it went into redemption, but the check already scheduled is sooner
+			name:      "later check",
+			entry:     Entry{Reason: ReasonRedemptionPeriod, EstimatedDropAt: at(35 * 24 * time.Hour), NextCheckAt: now.Add(24 * time.Hour)},
+			wantCheck: now.Add(time.Hour),
+		},
+		{
+			name:      "sooner check",
+			entry:     Entry{Reason: ReasonPendingDelete, EstimatedDropAt: at(30 * time.Minute), NextCheckAt: now.Add(time.Minute)},
+			wantCheck: now.Add(time.Minute),
+		},
+	}
+	for _, step := range steps {
+		step.entry.Domain = "example.test"
+		step.entry.AddedAt = now
+		if err := repo.WatchDomain(step.entry); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := repo.GetEntries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%s: watchlist = %+v, want just example.test", step.name, entries)
+		}
+		got := entries[0]
+		// the reason and estimate are always the latest
+		if got.Reason != step.entry.Reason || got.EstimatedDropAt == nil || !got.EstimatedDropAt.Equal(*step.entry.EstimatedDropAt) {
+			t.Errorf("%s: reason %q dropping at %v, want %q at %v", step.name, got.Reason, got.EstimatedDropAt, step.entry.Reason, step.entry.EstimatedDropAt)
+		}
+		if !got.NextCheckAt.Equal(step.wantCheck) {
+			t.Errorf("%s: next check at %v, want %v", step.name, got.NextCheckAt, step.wantCheck)
+		}
+	}
+
+	if next, err := repo.GetNextCheckAt(); err != nil || next == nil || !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("GetNextCheckAt() = %v, %v, want %v", next, err, now.Add(time.Minute))
+	}
+}
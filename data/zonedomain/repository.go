@@ -0,0 +1,227 @@
+package zonedomain
+
+import (
+	"database/sql"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+type (
+	Repository struct {
+		conn *sql.DB
+	}
+
+	Zone struct {
+		Zone     string
+		Domains  int
+		LoadedAt *time.Time
+	}
+)
+
+func NewRepository(conn *sql.DB) Repository {
+	return Repository{
+		conn: conn,
+	}
+}
+
+// DefaultLoadChunkSize is how many domains are committed per transaction while a zone is loaded
+const DefaultLoadChunkSize = 50_000
+
+/** LoadZone replaces every domain recorded for zone with domains, eg from zonefile.Delegations. The domains are
+ * committed chunkSize at a time as a new generation of the zone next to the one being served, which becomes the zone's
+ * generation in a single statement once they're all in, so lookups see either the old zone or the new one, never a
+ * partial load, without holding a write transaction open for the whole zone. The old generation is then deleted, again
+ * chunkSize rows at a time. Only zone's rows are ever touched, so loading a zone costs the same however many other
+ * zones have been loaded. Any error from domains aborts the load and leaves the old zone in place. Domains outside of
+ * zone are skipped. Returns the number of domains loaded, along with any error deleting the old generation since the
+ * load itself went through by then, whatever is left of it is deleted by the zone's next load.
+ *
+ * NOTE: different zones can be loaded concurrently, the same zone can't.
+ */
+func (repo Repository) LoadZone(zone string, domains iter.Seq2[string, error], chunkSize int, at time.Time) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultLoadChunkSize
+	}
+
+	var current int64
+	err := repo.conn.QueryRow("SELECT generation FROM zones WHERE zone = ?;", zone).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// whatever a failed load or cleanup left behind is thrown away
+	if err := repo.deleteGenerations(zone, current, chunkSize); err != nil {
+		return 0, err
+	}
+
+	next := current + 1
+	n, err := repo.insertGeneration(zone, next, domains, chunkSize)
+	if err != nil {
+		_ = repo.deleteGenerations(zone, current, chunkSize)
+		return 0, err
+	}
+
+	_, err = repo.conn.Exec(
+		`INSERT INTO zones (zone, domains, loaded_at, generation) VALUES (?, ?, ?, ?)
+		ON CONFLICT (zone) DO UPDATE SET
+		  domains = excluded.domains,
+		  loaded_at = excluded.loaded_at,
+		  generation = excluded.generation;`,
+		zone,
+		n,
+		utils.ToSQLiteDT(&at),
+		next,
+	)
+	if err != nil {
+		_ = repo.deleteGenerations(zone, current, chunkSize)
+		return 0, err
+	}
+
+	return n, repo.deleteGenerations(zone, next, chunkSize)
+}
+
+// deleteGenerations deletes every row of zone outside of generation keep, chunkSize rows per statement
+func (repo Repository) deleteGenerations(zone string, keep int64, chunkSize int) error {
+	// two ranges rather than != so each is a seek on the primary key instead of a scan over the zone
+	for _, outside := range []string{"generation < ?", "generation > ?"} {
+		for {
+			res, err := repo.conn.Exec(
+				`DELETE FROM zone_domains WHERE (zone, generation, label) IN (
+				  SELECT zone, generation, label FROM zone_domains
+				  WHERE zone = ? AND `+outside+`
+				  LIMIT ?
+				);`,
+				zone,
+				keep,
+				chunkSize,
+			)
+			if err != nil {
+				return err
+			}
+			deleted, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if deleted < int64(chunkSize) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// insertGeneration inserts the domains of zone as generation committing every chunkSize rows
+func (repo Repository) insertGeneration(zone string, generation int64, domains iter.Seq2[string, error], chunkSize int) (int, error) {
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+	begin := func() error {
+		var err error
+		tx, err = repo.conn.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err = tx.Prepare("INSERT OR IGNORE INTO zone_domains (zone, generation, label) VALUES (?, ?, ?);")
+		if err != nil {
+			_ = tx.Rollback()
+			tx = nil
+			return err
+		}
+		return nil
+	}
+	commit := func() error {
+		_ = stmt.Close()
+		err := tx.Commit()
+		tx, stmt = nil, nil
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			_ = stmt.Close()
+			_ = tx.Rollback()
+		}
+	}()
+
+	n, pending := 0, 0
+	for domain, err := range domains {
+		if err != nil {
+			return 0, err
+		}
+
+		label, parent, ok := strings.Cut(domain, ".")
+		if !ok || parent != zone {
+			continue
+		}
+		if tx == nil {
+			if err := begin(); err != nil {
+				return 0, err
+			}
+		}
+		res, err := stmt.Exec(zone, generation, label)
+		if err != nil {
+			return 0, err
+		}
+		// names repeated out of order are only counted once
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += int(inserted)
+		pending++
+
+		if pending >= chunkSize {
+			if err := commit(); err != nil {
+				return 0, err
+			}
+			pending = 0
+		}
+	}
+
+	if tx != nil {
+		if err := commit(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Contains reports whether domain is delegated in its zone, false if it isn't or the zone was never loaded
+func (repo Repository) Contains(domain string) (bool, error) {
+	label, zone, ok := strings.Cut(strings.ToLower(domain), ".")
+	if !ok {
+		return false, nil
+	}
+
+	var found int
+	err := repo.conn.QueryRow(
+		`SELECT 1 FROM zones z
+		JOIN zone_domains d ON d.zone = z.zone AND d.generation = z.generation AND d.label = ?
+		WHERE z.zone = ?;`,
+		label,
+		zone,
+	).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repo Repository) GetZones() ([]Zone, error) {
+	rows, err := repo.conn.Query("SELECT zone, domains, loaded_at FROM zones ORDER BY zone;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := make([]Zone, 0)
+	for rows.Next() {
+		var z Zone
+		if err := rows.Scan(&z.Zone, &z.Domains, &z.LoadedAt); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
@@ -0,0 +1,107 @@
+package zonedomain
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/infra/sqlite"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRepository(conn)
+}
+
+func domainsOf(domains ...string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for _, domain := range domains {
+			if !yield(domain, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestLoadZoneContains(t *testing.T) {
+	repo := newTestRepository(t)
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a chunk size of 2 commits the loads across several transactions
+	n, err := repo.LoadZone("com", domainsOf("a.com", "b.com", "b.com", "c.com", "d.net", "com"), 2, at)
+	if err != nil || n != 3 {
+		t.Fatalf("LoadZone(com) = %d, %v, want 3, nil", n, err)
+	}
+	if n, err := repo.LoadZone("net", domainsOf("d.net"), 2, at); err != nil || n != 1 {
+		t.Fatalf("LoadZone(net) = %d, %v, want 1, nil", n, err)
+	}
+
+	// reloading a zone replaces it without touching any other zone
+	if n, err := repo.LoadZone("com", domainsOf("b.com", "e.com"), 2, at.Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("LoadZone(com) again = %d, %v, want 2, nil", n, err)
+	}
+
+	// a failed load leaves the zone as it was
+	failing := func(yield func(string, error) bool) {
+		_ = yield("f.com", nil) && yield("g.com", nil) && yield("h.com", nil) && yield("", errors.New("read failed"))
+	}
+	if _, err := repo.LoadZone("com", failing, 2, at.Add(2*time.Hour)); err == nil {
+		t.Fatal("LoadZone() with a failing reader succeeded")
+	}
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"b.com", true},
+		{"E.com", true},
+		{"a.com", false},
+		{"c.com", false},
+		{"f.com", false},
+		{"d.net", true},
+		{"d.com", false},
+		{"a.org", false},
+		{"com", false},
+	}
+	for _, tt := range tests {
+		got, err := repo.Contains(tt.domain)
+		if err != nil {
+			t.Fatalf("Contains(%q) error = %v", tt.domain, err)
+		}
+		if got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+
+	zones, err := repo.GetZones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(zones))
+	for _, z := range zones {
+		got = append(got, z.Zone)
+		if z.Zone == "com" && (z.Domains != 2 || !z.LoadedAt.Equal(at.Add(time.Hour))) {
+			t.Errorf("zone com = %d domains loaded at %v, want 2 at %v", z.Domains, z.LoadedAt, at.Add(time.Hour))
+		}
+	}
+	if !slices.Equal(got, []string{"com", "net"}) {
+		t.Errorf("GetZones() = %v, want [com net]", got)
+	}
+
+	// only the generation being served is kept once a load is done
+	var rows int
+	if err := repo.conn.QueryRow("SELECT COUNT(*) FROM zone_domains WHERE zone = 'com';").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("zone com has %d rows, want 2", rows)
+	}
+}
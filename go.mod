@@ -4,9 +4,11 @@ go 1.25.3
 
 require (
 	github.com/joho/godotenv v1.5.1
+	github.com/khinshankhan/jitter-go/v2 v2.0.1
 	github.com/khinshankhan/logstox v0.1.0
 	github.com/khinshankhan/logstox/backend/zapx v0.1.0
 	github.com/openrdap/rdap v0.9.1
+	golang.org/x/net v0.41.0
 	golang.org/x/time v0.14.0
 	modernc.org/sqlite v1.40.0
 )
@@ -16,7 +18,6 @@ require (
 	github.com/alecthomas/units v0.0.0-20211218093645-b94a6e3cc137 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/khinshankhan/jitter-go/v2 v2.0.1 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
@@ -25,6 +25,9 @@ type Options struct {
 	WALAutoCheckpoint int
 
 	ReadOnly bool
+
+	// Migrate applies any pending embedded migrations when the connection is opened.
+	Migrate bool
 }
 
 func DefaultOptions(path string) Options {
@@ -40,6 +43,7 @@ func DefaultOptions(path string) Options {
 		CacheSizeKiB:      -8000,
 		MmapSizeBytes:     0,
 		WALAutoCheckpoint: 0,
+		Migrate:           true,
 	}
 }
 
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khinshankhan/nomex/utils"
+)
+
+// migrations are named <version>_<name>.<up|down>.sql, eg 0001_init.up.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	SQLiteMigrationNameError    = errors.New("sqlite: invalid migration file name")
+	SQLiteMigrationMissingError = errors.New("sqlite: migration is missing its up or down step")
+	SQLiteMigrationUnknownError = errors.New("sqlite: database has a migration applied that this build doesn't know about")
+)
+
+type (
+	Migration struct {
+		Version int
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	MigrationStatus struct {
+		Version   int
+		Name      string
+		AppliedAt *time.Time // nil if pending
+	}
+)
+
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+
+		base, direction, ok := strings.Cut(strings.TrimSuffix(filename, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: %s", SQLiteMigrationNameError, filename)
+		}
+		rawVersion, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", SQLiteMigrationNameError, filename)
+		}
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", SQLiteMigrationNameError, filename)
+		}
+
+		contents, err := migrationFiles.ReadFile(path.Join("migrations", filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m, found := byVersion[version]
+		if !found {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s", SQLiteMigrationMissingError, m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func ensureSchemaVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`)
+	return err
+}
+
+func getAppliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_version;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a single migration step and records it in schema_version within the same transaction.
+func applyMigration(db *sql.DB, m Migration, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	if _, err := tx.Exec(script); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("sqlite: migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		now := time.Now()
+		_, err = tx.Exec(
+			"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?);",
+			m.Version,
+			m.Name,
+			utils.ToSQLiteDT(&now),
+		)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_version WHERE version = ?;", m.Version)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrateUp applies every pending migration in order and returns how many were applied.
+func MigrateUp(db *sql.DB) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := getAppliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	known := make(map[int]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = struct{}{}
+	}
+	for version := range applied {
+		if _, found := known[version]; !found {
+			return 0, fmt.Errorf("%w: version %d", SQLiteMigrationUnknownError, version)
+		}
+	}
+
+	n := 0
+	for _, m := range migrations {
+		if _, found := applied[m.Version]; found {
+			continue
+		}
+		if err := applyMigration(db, m, true); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MigrateDown rolls back up to steps of the most recently applied migrations and returns how many were rolled back.
+func MigrateDown(db *sql.DB, steps int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := getAppliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := len(migrations) - 1; i >= 0 && n < steps; i-- {
+		m := migrations[i]
+		if _, found := applied[m.Version]; !found {
+			continue
+		}
+		if err := applyMigration(db, m, false); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// GetMigrationStatus reports every known migration along with when it was applied, if at all.
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := getAppliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if appliedAt, found := applied[m.Version]; found {
+			status.AppliedAt = &appliedAt
+		}
+		results = append(results, status)
+	}
+	return results, nil
+}
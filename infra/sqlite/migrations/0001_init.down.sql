@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS banned;
+DROP TABLE IF EXISTS checks;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS checks (
+  domain     TEXT PRIMARY KEY,
+  code       INTEGER,
+  checked_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS banned (
+  domain TEXT PRIMARY KEY,
+  reason TEXT,
+  ban_at DATETIME
+);
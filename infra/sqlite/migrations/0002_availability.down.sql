@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS checks_availability;
+
+ALTER TABLE checks ADD COLUMN code INTEGER;
+
+UPDATE checks
+SET code = CASE
+  WHEN availability IS NULL THEN NULL
+  WHEN availability = 'registered' THEN 200
+  WHEN availability = 'available' THEN 404
+  WHEN availability = 'reserved' THEN 200
+  WHEN error_class = 'input' THEN 400
+  WHEN error_class = 'timeout' THEN 504
+  WHEN error_class = 'rate_limited' THEN 429
+  WHEN error_class = 'canceled' THEN 499
+  WHEN error_class = 'dns' THEN 500
+  WHEN error_class = 'unsupported' THEN 501
+  WHEN error_class = 'unavailable' THEN 503
+  ELSE 502
+END;
+
+ALTER TABLE checks DROP COLUMN error_class;
+ALTER TABLE checks DROP COLUMN availability;
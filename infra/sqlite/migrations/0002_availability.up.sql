@@ -0,0 +1,29 @@
+ALTER TABLE checks ADD COLUMN availability TEXT;
+ALTER TABLE checks ADD COLUMN error_class TEXT;
+
+-- map the old pseudo http codes onto the typed status
+UPDATE checks
+SET
+  availability = CASE
+    WHEN code IS NULL THEN NULL
+    WHEN code = 200 THEN 'registered'
+    WHEN code = 404 THEN 'available'
+    WHEN code = 501 THEN 'unknown'
+    ELSE 'error'
+  END,
+  error_class = CASE
+    WHEN code IS NULL OR code IN (200, 404) THEN NULL
+    WHEN code = 400 THEN 'input'
+    WHEN code IN (408, 504) THEN 'timeout'
+    WHEN code = 429 THEN 'rate_limited'
+    WHEN code = 499 THEN 'canceled'
+    WHEN code = 500 THEN 'dns'
+    WHEN code = 501 THEN 'unsupported'
+    WHEN code = 502 THEN 'upstream'
+    WHEN code = 503 THEN 'unavailable'
+    ELSE 'internal'
+  END;
+
+ALTER TABLE checks DROP COLUMN code;
+
+CREATE INDEX IF NOT EXISTS checks_availability ON checks (availability);
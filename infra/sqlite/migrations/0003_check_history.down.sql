@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS check_history_checked_at;
+DROP INDEX IF EXISTS check_history_domain;
+DROP TABLE IF EXISTS check_history;
@@ -0,0 +1,18 @@
+-- append only log of every check, checks only keeps the latest state
+CREATE TABLE IF NOT EXISTS check_history (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  domain       TEXT NOT NULL,
+  availability TEXT,
+  error_class  TEXT,
+  checked_at   DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS check_history_domain ON check_history (domain, id);
+CREATE INDEX IF NOT EXISTS check_history_checked_at ON check_history (checked_at);
+
+-- seed with the latest state we already know about
+INSERT INTO check_history (domain, availability, error_class, checked_at)
+SELECT domain, availability, error_class, checked_at
+FROM checks
+WHERE checked_at IS NOT NULL
+ORDER BY checked_at ASC;
@@ -0,0 +1,11 @@
+ALTER TABLE check_history DROP COLUMN rdap_server;
+ALTER TABLE check_history DROP COLUMN error;
+ALTER TABLE check_history DROP COLUMN latency_ms;
+ALTER TABLE check_history DROP COLUMN attempts;
+ALTER TABLE check_history DROP COLUMN source;
+
+ALTER TABLE checks DROP COLUMN rdap_server;
+ALTER TABLE checks DROP COLUMN error;
+ALTER TABLE checks DROP COLUMN latency_ms;
+ALTER TABLE checks DROP COLUMN attempts;
+ALTER TABLE checks DROP COLUMN source;
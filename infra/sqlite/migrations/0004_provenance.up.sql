@@ -0,0 +1,12 @@
+-- which backend decided each result and how it got there
+ALTER TABLE checks ADD COLUMN source TEXT;
+ALTER TABLE checks ADD COLUMN attempts INTEGER;
+ALTER TABLE checks ADD COLUMN latency_ms INTEGER;
+ALTER TABLE checks ADD COLUMN error TEXT;
+ALTER TABLE checks ADD COLUMN rdap_server TEXT;
+
+ALTER TABLE check_history ADD COLUMN source TEXT;
+ALTER TABLE check_history ADD COLUMN attempts INTEGER;
+ALTER TABLE check_history ADD COLUMN latency_ms INTEGER;
+ALTER TABLE check_history ADD COLUMN error TEXT;
+ALTER TABLE check_history ADD COLUMN rdap_server TEXT;
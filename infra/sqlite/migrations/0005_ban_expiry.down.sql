@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS banned_expires_at;
+
+ALTER TABLE banned DROP COLUMN strikes;
+ALTER TABLE banned DROP COLUMN expires_at;
+ALTER TABLE banned DROP COLUMN category;
@@ -0,0 +1,14 @@
+-- permanent bans never expire, transient bans expire and return the domain to the pending queue
+ALTER TABLE banned ADD COLUMN category TEXT NOT NULL DEFAULT 'permanent';
+ALTER TABLE banned ADD COLUMN expires_at DATETIME;
+ALTER TABLE banned ADD COLUMN strikes INTEGER NOT NULL DEFAULT 0;
+
+-- these were only ever written for transient failures, give them the base cooldown
+UPDATE banned
+SET
+  category = 'transient',
+  strikes = 1,
+  expires_at = strftime('%Y-%m-%dT%H:%M:%fZ', ban_at, '+1 hour')
+WHERE reason IN ('temporary DNS failure', 'timeout');
+
+CREATE INDEX IF NOT EXISTS banned_expires_at ON banned (expires_at);
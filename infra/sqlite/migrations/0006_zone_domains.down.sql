@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS zone_domains;
+DROP TABLE IF EXISTS zones;
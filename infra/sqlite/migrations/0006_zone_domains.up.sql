@@ -0,0 +1,14 @@
+-- zones which have been loaded from a zone file, a domain missing from zone_domains only means something if its zone
+-- is listed here
+CREATE TABLE IF NOT EXISTS zones (
+  zone      TEXT PRIMARY KEY,
+  domains   INTEGER NOT NULL,
+  loaded_at DATETIME
+);
+
+-- every domain delegated in a loaded zone, keyed by label so the zone isn't repeated in the key for each row
+CREATE TABLE IF NOT EXISTS zone_domains (
+  zone  TEXT NOT NULL,
+  label TEXT NOT NULL,
+  PRIMARY KEY (zone, label)
+) WITHOUT ROWID;
@@ -0,0 +1,6 @@
+-- dns answers kept between runs, each is only valid until its ttl runs out
+CREATE TABLE IF NOT EXISTS dns_cache (
+  domain     TEXT PRIMARY KEY,
+  status     TEXT NOT NULL,
+  expires_at DATETIME NOT NULL
+);
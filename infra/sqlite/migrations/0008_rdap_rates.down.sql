@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rdap_rates;
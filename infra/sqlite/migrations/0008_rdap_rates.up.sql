@@ -0,0 +1,6 @@
+-- the rate each rdap server was last found to put up with, so a run starts where the previous one left off
+CREATE TABLE IF NOT EXISTS rdap_rates (
+  server      TEXT PRIMARY KEY,
+  interval_ms INTEGER NOT NULL,
+  updated_at  DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS registration_statuses;
+DROP TABLE IF EXISTS registrations;
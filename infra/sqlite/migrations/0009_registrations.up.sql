@@ -0,0 +1,22 @@
+-- what rdap says about registered domains, the latest answer only
+CREATE TABLE IF NOT EXISTS registrations (
+  domain            TEXT PRIMARY KEY,
+  registered_at     DATETIME,
+  expires_at        DATETIME,
+  last_changed_at   DATETIME,
+  registrar         TEXT,
+  registrar_iana_id TEXT,
+  nameservers       TEXT NOT NULL DEFAULT '', -- comma separated
+  checked_at        DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS registrations_expires_at ON registrations (expires_at);
+
+-- epp statuses of each registration, eg redemptionPeriod or pendingDelete, kept apart so domains can be found by status
+CREATE TABLE IF NOT EXISTS registration_statuses (
+  domain TEXT NOT NULL,
+  status TEXT NOT NULL,
+  PRIMARY KEY (domain, status)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS registration_statuses_status ON registration_statuses (status);
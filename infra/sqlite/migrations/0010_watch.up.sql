@@ -0,0 +1,22 @@
+-- domains expected to drop soon, rechecked more and more often as their estimated drop gets closer
+CREATE TABLE IF NOT EXISTS watch (
+  domain            TEXT PRIMARY KEY,
+  reason            TEXT NOT NULL, -- why it's expected to drop, eg redemptionPeriod, pendingDelete or expiring
+  estimated_drop_at DATETIME,
+  next_check_at     DATETIME NOT NULL,
+  last_checked_at   DATETIME,
+  checks            INTEGER NOT NULL DEFAULT 0,
+  added_at          DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS watch_next_check_at ON watch (next_check_at);
+
+-- what happened to watched domains, eg dropped or renewed
+CREATE TABLE IF NOT EXISTS watch_events (
+  id     INTEGER PRIMARY KEY AUTOINCREMENT,
+  domain TEXT NOT NULL,
+  kind   TEXT NOT NULL,
+  at     DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS watch_events_at ON watch_events (at);
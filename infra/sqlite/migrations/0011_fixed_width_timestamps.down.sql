@@ -0,0 +1 @@
+-- fixed width timestamps are still valid RFC3339, there is nothing to undo
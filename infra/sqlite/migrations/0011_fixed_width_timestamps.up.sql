@@ -0,0 +1,114 @@
+-- timestamps used to be written with RFC3339Nano which trims trailing zeros, so they didn't compare correctly as text
+-- at sub second boundaries (eg "05.5Z" sorted before "05Z"), pad every fraction out to 9 digits
+
+UPDATE checks
+SET checked_at = CASE
+    WHEN instr(checked_at, '.') = 0 THEN substr(checked_at, 1, 19) || '.000000000Z'
+    ELSE substr(checked_at, 1, 20) || substr(substr(checked_at, 21, length(checked_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE checked_at IS NOT NULL AND length(checked_at) != 30;
+
+UPDATE check_history
+SET checked_at = CASE
+    WHEN instr(checked_at, '.') = 0 THEN substr(checked_at, 1, 19) || '.000000000Z'
+    ELSE substr(checked_at, 1, 20) || substr(substr(checked_at, 21, length(checked_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE checked_at IS NOT NULL AND length(checked_at) != 30;
+
+UPDATE banned
+SET ban_at = CASE
+    WHEN instr(ban_at, '.') = 0 THEN substr(ban_at, 1, 19) || '.000000000Z'
+    ELSE substr(ban_at, 1, 20) || substr(substr(ban_at, 21, length(ban_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE ban_at IS NOT NULL AND length(ban_at) != 30;
+
+UPDATE banned
+SET expires_at = CASE
+    WHEN instr(expires_at, '.') = 0 THEN substr(expires_at, 1, 19) || '.000000000Z'
+    ELSE substr(expires_at, 1, 20) || substr(substr(expires_at, 21, length(expires_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE expires_at IS NOT NULL AND length(expires_at) != 30;
+
+UPDATE zones
+SET loaded_at = CASE
+    WHEN instr(loaded_at, '.') = 0 THEN substr(loaded_at, 1, 19) || '.000000000Z'
+    ELSE substr(loaded_at, 1, 20) || substr(substr(loaded_at, 21, length(loaded_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE loaded_at IS NOT NULL AND length(loaded_at) != 30;
+
+UPDATE dns_cache
+SET expires_at = CASE
+    WHEN instr(expires_at, '.') = 0 THEN substr(expires_at, 1, 19) || '.000000000Z'
+    ELSE substr(expires_at, 1, 20) || substr(substr(expires_at, 21, length(expires_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE expires_at IS NOT NULL AND length(expires_at) != 30;
+
+UPDATE rdap_rates
+SET updated_at = CASE
+    WHEN instr(updated_at, '.') = 0 THEN substr(updated_at, 1, 19) || '.000000000Z'
+    ELSE substr(updated_at, 1, 20) || substr(substr(updated_at, 21, length(updated_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE updated_at IS NOT NULL AND length(updated_at) != 30;
+
+UPDATE registrations
+SET registered_at = CASE
+    WHEN instr(registered_at, '.') = 0 THEN substr(registered_at, 1, 19) || '.000000000Z'
+    ELSE substr(registered_at, 1, 20) || substr(substr(registered_at, 21, length(registered_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE registered_at IS NOT NULL AND length(registered_at) != 30;
+
+UPDATE registrations
+SET expires_at = CASE
+    WHEN instr(expires_at, '.') = 0 THEN substr(expires_at, 1, 19) || '.000000000Z'
+    ELSE substr(expires_at, 1, 20) || substr(substr(expires_at, 21, length(expires_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE expires_at IS NOT NULL AND length(expires_at) != 30;
+
+UPDATE registrations
+SET last_changed_at = CASE
+    WHEN instr(last_changed_at, '.') = 0 THEN substr(last_changed_at, 1, 19) || '.000000000Z'
+    ELSE substr(last_changed_at, 1, 20) || substr(substr(last_changed_at, 21, length(last_changed_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE last_changed_at IS NOT NULL AND length(last_changed_at) != 30;
+
+UPDATE registrations
+SET checked_at = CASE
+    WHEN instr(checked_at, '.') = 0 THEN substr(checked_at, 1, 19) || '.000000000Z'
+    ELSE substr(checked_at, 1, 20) || substr(substr(checked_at, 21, length(checked_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE checked_at IS NOT NULL AND length(checked_at) != 30;
+
+UPDATE watch
+SET estimated_drop_at = CASE
+    WHEN instr(estimated_drop_at, '.') = 0 THEN substr(estimated_drop_at, 1, 19) || '.000000000Z'
+    ELSE substr(estimated_drop_at, 1, 20) || substr(substr(estimated_drop_at, 21, length(estimated_drop_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE estimated_drop_at IS NOT NULL AND length(estimated_drop_at) != 30;
+
+UPDATE watch
+SET next_check_at = CASE
+    WHEN instr(next_check_at, '.') = 0 THEN substr(next_check_at, 1, 19) || '.000000000Z'
+    ELSE substr(next_check_at, 1, 20) || substr(substr(next_check_at, 21, length(next_check_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE next_check_at IS NOT NULL AND length(next_check_at) != 30;
+
+UPDATE watch
+SET last_checked_at = CASE
+    WHEN instr(last_checked_at, '.') = 0 THEN substr(last_checked_at, 1, 19) || '.000000000Z'
+    ELSE substr(last_checked_at, 1, 20) || substr(substr(last_checked_at, 21, length(last_checked_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE last_checked_at IS NOT NULL AND length(last_checked_at) != 30;
+
+UPDATE watch
+SET added_at = CASE
+    WHEN instr(added_at, '.') = 0 THEN substr(added_at, 1, 19) || '.000000000Z'
+    ELSE substr(added_at, 1, 20) || substr(substr(added_at, 21, length(added_at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE added_at IS NOT NULL AND length(added_at) != 30;
+
+UPDATE watch_events
+SET at = CASE
+    WHEN instr(at, '.') = 0 THEN substr(at, 1, 19) || '.000000000Z'
+    ELSE substr(at, 1, 20) || substr(substr(at, 21, length(at) - 21) || '000000000', 1, 9) || 'Z'
+  END
+WHERE at IS NOT NULL AND length(at) != 30;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS zone_domains_single (
+  zone  TEXT NOT NULL,
+  label TEXT NOT NULL,
+  PRIMARY KEY (zone, label)
+) WITHOUT ROWID;
+
+-- only the generation being served, anything else is a load that never finished or hasn't been cleaned up yet
+INSERT INTO zone_domains_single (zone, label)
+SELECT d.zone, d.label FROM zone_domains d
+JOIN zones z ON z.zone = d.zone AND z.generation = d.generation;
+
+DROP TABLE zone_domains;
+ALTER TABLE zone_domains_single RENAME TO zone_domains;
+
+ALTER TABLE zones DROP COLUMN generation;
@@ -0,0 +1,19 @@
+-- a zone is loaded as a new generation of rows next to the one being served, which switching zones.generation makes
+-- visible, so a load only ever touches the rows of its own zone and the old generation is deleted once it's replaced
+ALTER TABLE zones ADD COLUMN generation INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS zone_domains_generations (
+  zone       TEXT NOT NULL,
+  generation INTEGER NOT NULL,
+  label      TEXT NOT NULL,
+  PRIMARY KEY (zone, generation, label)
+) WITHOUT ROWID;
+
+INSERT INTO zone_domains_generations (zone, generation, label)
+SELECT zone, 0, label FROM zone_domains;
+
+DROP TABLE zone_domains;
+ALTER TABLE zone_domains_generations RENAME TO zone_domains;
+
+-- loads used to be staged in a copy of every zone, a failed one may have left it behind
+DROP TABLE IF EXISTS zone_domains_staging;
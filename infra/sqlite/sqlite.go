@@ -46,6 +46,14 @@ func GetConnection(opts Options) (*sql.DB, error) {
 		_ = conn.Close()
 		return nil, err
 	}
+
+	// read only connections can't record schema changes so they're left as is
+	if opts.Migrate && !opts.ReadOnly {
+		if _, err := MigrateUp(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
 	return conn, nil
 }
 
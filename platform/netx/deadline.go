@@ -0,0 +1,33 @@
+package netx
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+/** MaxResponseSize caps what's read from servers whose responses are a few KB at most (eg whois or epp), anything much
+ * larger isn't a response worth parsing.
+ */
+const MaxResponseSize = 1 << 20
+
+/** BindContext ties conn to ctx: the conn's deadline becomes ctx's, or fallback from now if ctx has none (0 for no
+ * deadline at all), and cancelling ctx before then unblocks any pending reads and writes. The returned func unbinds
+ * them and should be deferred.
+ */
+func BindContext(ctx context.Context, conn net.Conn, fallback time.Duration) (func(), error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && fallback > 0 {
+		deadline, ok = time.Now().Add(fallback), true
+	}
+	if ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	return func() { stop() }, nil
+}
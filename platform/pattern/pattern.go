@@ -0,0 +1,345 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/** Pattern describes a space of domain names, eg "CVC.net", "[a-z0-9]{3}.io", "L?L.dev" or "get[a-z]{2}.app".
+ *
+ * Grammar:
+ *   C        consonant (bcdfghjklmnpqrstvwxyz)
+ *   V        vowel (aeiou)
+ *   L        letter (a-z)
+ *   D        digit (0-9)
+ *   A        letter or digit
+ *   [...]    character class with ranges, eg [a-z0-9] or [xyz-]
+ *   {n}      repeat the previous element exactly n times
+ *   {n,m}    repeat the previous element between n and m times
+ *   ?        previous element is optional, same as {0,1}
+ *   \x       literal x, where x is a letter, digit, '-' or '.' (letters are lowercased)
+ *   a-z 0-9 - .  literal characters, '.' separates labels
+ *
+ * Every name a pattern expands to must be valid, so Parse rejects patterns where a label could come out empty, longer
+ * than 63 characters, or starting or ending with a hyphen.
+ *
+ * NOTE: ambiguous patterns (eg "L?L?") can produce the same name more than once, Cardinality counts every expansion
+ * rather than distinct names since that's what callers end up iterating over.
+ */
+type Pattern struct {
+	source string
+	tokens []token
+}
+
+type token struct {
+	chars    []byte
+	min, max int
+	pos      int // offset in the source, for errors
+}
+
+const (
+	consonants = "bcdfghjklmnpqrstvwxyz"
+	vowels     = "aeiou"
+	letters    = "abcdefghijklmnopqrstuvwxyz"
+	digits     = "0123456789"
+
+	// a single label can't be longer than this so there's no reason to repeat more
+	maxLabelLength = 63
+	maxRepeat      = maxLabelLength
+)
+
+var (
+	PatternSyntaxError = errors.New("pattern: invalid syntax")
+	PatternEmptyError  = errors.New("pattern: pattern cannot be empty")
+)
+
+var shorthands = map[byte]string{
+	'C': consonants,
+	'V': vowels,
+	'L': letters,
+	'D': digits,
+	'A': letters + digits,
+}
+
+func isLiteral(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.'
+}
+
+func syntaxError(source string, pos int, format string, args ...any) error {
+	return fmt.Errorf("%w: %q at %d: %s", PatternSyntaxError, source, pos, fmt.Sprintf(format, args...))
+}
+
+// Parse compiles a pattern, see Pattern for the grammar.
+func Parse(source string) (Pattern, error) {
+	if source == "" {
+		return Pattern{}, PatternEmptyError
+	}
+
+	p := Pattern{source: source}
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == '[':
+			end := strings.IndexByte(source[i+1:], ']')
+			if end < 0 {
+				return Pattern{}, syntaxError(source, i, "unterminated character class")
+			}
+			chars, err := parseClass(source, i+1, source[i+1:i+1+end])
+			if err != nil {
+				return Pattern{}, err
+			}
+			p.tokens = append(p.tokens, token{chars: chars, min: 1, max: 1, pos: i})
+			i += end + 1
+
+		case c == '{':
+			if len(p.tokens) == 0 {
+				return Pattern{}, syntaxError(source, i, "repetition without a preceding element")
+			}
+			end := strings.IndexByte(source[i+1:], '}')
+			if end < 0 {
+				return Pattern{}, syntaxError(source, i, "unterminated repetition")
+			}
+			min, max, err := parseRepeat(source, i, source[i+1:i+1+end])
+			if err != nil {
+				return Pattern{}, err
+			}
+			last := &p.tokens[len(p.tokens)-1]
+			last.min, last.max = last.min*min, last.max*max
+			if last.max > maxRepeat {
+				return Pattern{}, syntaxError(source, i, "repetition exceeds %d", maxRepeat)
+			}
+			i += end + 1
+
+		case c == '?':
+			if len(p.tokens) == 0 {
+				return Pattern{}, syntaxError(source, i, "'?' without a preceding element")
+			}
+			p.tokens[len(p.tokens)-1].min = 0
+
+		case c == '\\':
+			if i+1 >= len(source) {
+				return Pattern{}, syntaxError(source, i, "trailing escape")
+			}
+			i++
+			escaped := lower(source[i])
+			if !isLiteral(escaped) {
+				return Pattern{}, syntaxError(source, i, "can't escape %q, only letters, digits, '-' and '.' are allowed in names", source[i])
+			}
+			p.tokens = append(p.tokens, token{chars: []byte{escaped}, min: 1, max: 1, pos: i - 1})
+
+		case shorthands[c] != "":
+			p.tokens = append(p.tokens, token{chars: []byte(shorthands[c]), min: 1, max: 1, pos: i})
+
+		case isLiteral(c):
+			p.tokens = append(p.tokens, token{chars: []byte{c}, min: 1, max: 1, pos: i})
+
+		default:
+			return Pattern{}, syntaxError(source, i, "unexpected character %q", c)
+		}
+	}
+
+	if err := p.validate(); err != nil {
+		return Pattern{}, err
+	}
+	return p, nil
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func (t token) has(c byte) bool {
+	return slices.Contains(t.chars, c)
+}
+
+// validate checks that every label the pattern expands to is a valid LDH label
+func (p Pattern) validate() error {
+	var labels [][]token
+	label := []token{}
+	for _, t := range p.tokens {
+		if !t.has('.') {
+			label = append(label, t)
+			continue
+		}
+		// classes can't hold a '.', so this is a lone separator which must appear exactly once
+		if t.min != 1 || t.max != 1 {
+			return syntaxError(p.source, t.pos, "'.' can't be repeated or optional")
+		}
+		labels = append(labels, label)
+		label = []token{}
+	}
+	labels = append(labels, label)
+
+	for _, label := range labels {
+		minLen, maxLen := 0, 0
+		for _, t := range label {
+			minLen, maxLen = minLen+t.min, maxLen+t.max
+		}
+		pos := len(p.source)
+		if len(label) > 0 {
+			pos = label[0].pos
+		}
+		if minLen == 0 {
+			return syntaxError(p.source, pos, "label can be empty")
+		}
+		if maxLen > maxLabelLength {
+			return syntaxError(p.source, pos, "label can be longer than %d characters", maxLabelLength)
+		}
+
+		// any token up to and including the first required one can start the label, likewise for the end
+		for _, t := range label {
+			if t.has('-') {
+				return syntaxError(p.source, t.pos, "label can start with '-'")
+			}
+			if t.min > 0 {
+				break
+			}
+		}
+		for i := len(label) - 1; i >= 0; i-- {
+			if label[i].has('-') {
+				return syntaxError(p.source, label[i].pos, "label can end with '-'")
+			}
+			if label[i].min > 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// MustParse is like Parse but panics if the pattern is invalid.
+func MustParse(source string) Pattern {
+	p, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func parseClass(source string, offset int, class string) ([]byte, error) {
+	if class == "" {
+		return nil, syntaxError(source, offset, "empty character class")
+	}
+
+	seen := make(map[byte]struct{})
+	chars := []byte{}
+	add := func(c byte) {
+		if _, found := seen[c]; !found {
+			seen[c] = struct{}{}
+			chars = append(chars, c)
+		}
+	}
+
+	for i := 0; i < len(class); i++ {
+		lo := class[i]
+		if !isLiteral(lo) || lo == '.' {
+			return nil, syntaxError(source, offset+i, "unexpected character %q in class", lo)
+		}
+
+		// a trailing or leading '-' is a literal hyphen
+		if i+2 < len(class) && class[i+1] == '-' {
+			hi := class[i+2]
+			if hi < lo || !isLiteral(hi) || hi == '.' || hi == '-' {
+				return nil, syntaxError(source, offset+i, "invalid range %c-%c", lo, hi)
+			}
+			for c := lo; c <= hi; c++ {
+				if isLiteral(c) && c != '.' {
+					add(c)
+				}
+			}
+			i += 2
+			continue
+		}
+		add(lo)
+	}
+
+	return chars, nil
+}
+
+func parseRepeat(source string, offset int, body string) (int, int, error) {
+	rawMin, rawMax, isRange := strings.Cut(body, ",")
+	min, err := strconv.Atoi(strings.TrimSpace(rawMin))
+	if err != nil || min < 0 {
+		return 0, 0, syntaxError(source, offset, "invalid repetition {%s}", body)
+	}
+	if !isRange {
+		return min, min, nil
+	}
+
+	max, err := strconv.Atoi(strings.TrimSpace(rawMax))
+	if err != nil || max < min {
+		return 0, 0, syntaxError(source, offset, "invalid repetition {%s}", body)
+	}
+	return min, max, nil
+}
+
+func (p Pattern) String() string {
+	return p.source
+}
+
+// Cardinality returns the exact number of names the pattern expands to without expanding it.
+func (p Pattern) Cardinality() *big.Int {
+	total := big.NewInt(1)
+	for _, t := range p.tokens {
+		base := big.NewInt(int64(len(t.chars)))
+
+		// sum of |chars|^k for every allowed repetition count k
+		sum := new(big.Int)
+		for k := t.min; k <= t.max; k++ {
+			sum.Add(sum, new(big.Int).Exp(base, big.NewInt(int64(k)), nil))
+		}
+		total.Mul(total, sum)
+	}
+	return total
+}
+
+// All streams every name the pattern describes, shorter repetitions first, without materializing the space.
+func (p Pattern) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		maxLen := 0
+		for _, t := range p.tokens {
+			maxLen += t.max
+		}
+
+		var rec func(ti int, buf []byte) bool
+		rec = func(ti int, buf []byte) bool {
+			if ti == len(p.tokens) {
+				return yield(string(buf))
+			}
+
+			t := p.tokens[ti]
+			for n := t.min; n <= t.max; n++ {
+				if !t.repeat(buf, n, func(next []byte) bool { return rec(ti+1, next) }) {
+					return false
+				}
+			}
+			return true
+		}
+		rec(0, make([]byte, 0, maxLen))
+	}
+}
+
+// Expand materializes every name the pattern describes, prefer All for large spaces.
+func (p Pattern) Expand() []string {
+	return slices.Collect(p.All())
+}
+
+func (t token) repeat(buf []byte, n int, next func([]byte) bool) bool {
+	if n == 0 {
+		return next(buf)
+	}
+	for _, c := range t.chars {
+		if !t.repeat(append(buf, c), n-1, next) {
+			return false
+		}
+	}
+	return true
+}
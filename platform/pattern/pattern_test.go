@@ -0,0 +1,78 @@
+package pattern
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRejectsInvalidLabels(t *testing.T) {
+	tests := []string{
+		`\!.net`,     // escape outside LDH
+		`a\_b.net`,   // escape outside LDH
+		`[-a].net`,   // class can start the label with a hyphen
+		`a[a-]`,      // class can end the label with a hyphen
+		`-abc.net`,   // literal leading hyphen
+		`abc-.net`,   // literal trailing hyphen
+		`L?-L.net`,   // hyphen becomes first when the optional letter is left out
+		`L-L?.net`,   // hyphen becomes last when the optional letter is left out
+		`.net`,       // empty label
+		`a..net`,     // empty label
+		`L?.net`,     // label can be empty
+		`a.?net`,     // optional separator
+		`L{40}D{30}`, // label longer than 63
+		`[--a]`,      // range starting at a hyphen
+	}
+	for _, source := range tests {
+		if _, err := Parse(source); !errors.Is(err, PatternSyntaxError) {
+			t.Errorf("Parse(%q) = %v, want %v", source, err, PatternSyntaxError)
+		}
+	}
+}
+
+func TestParseAcceptsValidLabels(t *testing.T) {
+	tests := map[string][]string{
+		`a-b.net`:    {"a-b.net"},
+		`a[-b]c.io`:  {"a-c.io", "abc.io"},
+		`\C\-\D.net`: {"c-d.net"},
+		`a\.b`:       {"a.b"},
+		`x-?y.dev`:   {"xy.dev", "x-y.dev"},
+	}
+	for source, want := range tests {
+		p, err := Parse(source)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", source, err)
+			continue
+		}
+		got := p.Expand()
+		if len(got) != len(want) {
+			t.Errorf("Parse(%q).Expand() = %v, want %v", source, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Parse(%q).Expand() = %v, want %v", source, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestCardinalityMatchesExpand(t *testing.T) {
+	tests := []string{
+		"CVC.net",
+		"L?L.dev",
+		"L?L?L.io",
+		"[a-c0-1]{1,3}.app",
+		"get[a-z]{2}.app",
+		"a[-b]{0,2}c.net",
+		"V{2,3}.V.com",
+		"D{2}",
+	}
+	for _, source := range tests {
+		p := MustParse(source)
+		want := len(p.Expand())
+		if got := p.Cardinality(); !got.IsInt64() || got.Int64() != int64(want) {
+			t.Errorf("Parse(%q).Cardinality() = %s, want %d", source, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package wordlist
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"strings"
+)
+
+type Options struct {
+	MinLength int      // minimum label length (after affixes), 0 for no minimum
+	MaxLength int      // maximum label length (after affixes), 0 for no maximum
+	Prefixes  []string // eg "get", "try", the bare word is always included
+	Suffixes  []string // eg "-hq", "-app", the bare word is always included
+	TLDs      []string // eg "net", "io"
+}
+
+func isLabelChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-'
+}
+
+// normalize lowercases and trims a word, returns false if it can't be used in a label (eg "can't", "café")
+func normalize(word string) (string, bool) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return "", false
+	}
+	for _, c := range word {
+		if !isLabelChar(c) {
+			return "", false
+		}
+	}
+	return word, true
+}
+
+// Words streams usable words from r one per line, blank lines and # comments are skipped. Any read error is yielded
+// once and ends the stream.
+func Words(r io.Reader) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+
+			word, ok := normalize(line)
+			if !ok {
+				continue
+			}
+			if !yield(word, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// normalizeAll lowercases and trims each value and strips a leading '.', dropping any left empty
+func normalizeAll(values []string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), ".")
+		if v != "" {
+			normalized = append(normalized, v)
+		}
+	}
+	return normalized
+}
+
+func validLabel(label string, opts Options) bool {
+	// affixes aren't checked when they're passed in so this is where they're caught
+	for _, c := range label {
+		if !isLabelChar(c) {
+			return false
+		}
+	}
+	if opts.MinLength > 0 && len(label) < opts.MinLength {
+		return false
+	}
+	// labels can't exceed 63 characters regardless of what was asked for
+	if (opts.MaxLength > 0 && len(label) > opts.MaxLength) || len(label) > 63 {
+		return false
+	}
+	// hyphens can't lead or trail and "ab--" is reserved for IDNA style labels like "xn--"
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return false
+	}
+	return len(label) < 4 || label[2:4] != "--"
+}
+
+/** Expand crosses every word with the prefixes, suffixes and tlds, skipping labels which aren't valid or are outside
+ * the length bounds. Affixes and tlds are normalized first, so " .NET " is the same as "net".
+ */
+func Expand(words iter.Seq[string], opts Options) iter.Seq[string] {
+	// the bare word is always a candidate
+	prefixes := append([]string{""}, normalizeAll(opts.Prefixes)...)
+	suffixes := append([]string{""}, normalizeAll(opts.Suffixes)...)
+	tlds := normalizeAll(opts.TLDs)
+
+	return func(yield func(string) bool) {
+		for word := range words {
+			for _, prefix := range prefixes {
+				for _, suffix := range suffixes {
+					label := prefix + word + suffix
+					if !validLabel(label, opts) {
+						continue
+					}
+					for _, tld := range tlds {
+						if !yield(label + "." + tld) {
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package wordlist
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name  string
+		words string
+		opts  Options
+		want  []string
+	}{
+		{
+			name:  "tlds are normalized",
+			words: "cat",
+			opts:  Options{TLDs: []string{".net", " IO ", ""}},
+			want:  []string{"cat.net", "cat.io"},
+		},
+		{
+			name:  "affixes are normalized",
+			words: "cat",
+			opts:  Options{Prefixes: []string{" Get"}, Suffixes: []string{"-HQ "}, TLDs: []string{"net"}},
+			want:  []string{"cat.net", "cat-hq.net", "getcat.net", "getcat-hq.net"},
+		},
+		{
+			name:  "affixes which make the label invalid are skipped",
+			words: "cat\ndog",
+			opts:  Options{Prefixes: []string{"-", "my_"}, Suffixes: []string{"!", "-"}, TLDs: []string{"net"}},
+			want:  []string{"cat.net", "dog.net"},
+		},
+		{
+			name:  "length bounds include affixes",
+			words: "cat\nhorse",
+			opts:  Options{MaxLength: 5, Prefixes: []string{"go"}, TLDs: []string{"net"}},
+			want:  []string{"cat.net", "gocat.net", "horse.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var words []string
+			for word, err := range Words(strings.NewReader(tt.words)) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				words = append(words, word)
+			}
+
+			got := slices.Collect(Expand(slices.Values(words), tt.opts))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package zonefile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"iter"
+	"os"
+	"strings"
+)
+
+// lines in zone files are short but TXT records can be long, this is plenty without being unbounded
+const maxLineSize = 1 << 20
+
+var (
+	classes = map[string]struct{}{"in": {}, "ch": {}, "hs": {}, "cs": {}}
+	// parentheses only group lines, once joined they're just noise
+	parens = strings.NewReplacer("(", " ", ")", " ")
+)
+
+type file struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (f file) Close() error {
+	_ = f.Reader.Close()
+	return f.f.Close()
+}
+
+// Open opens a zone file for reading, transparently decompressing it if it's gzipped (as published by CZDS)
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var magic [2]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if magic != [2]byte{0x1f, 0x8b} {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return file{Reader: gz, f: f}, nil
+}
+
+// stripComment drops everything after a ; that isn't quoted, and reports the change in parenthesis depth
+func stripComment(line string) (string, int) {
+	quoted, depth := false, 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';':
+			return line[:i], depth
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		}
+	}
+	return line, depth
+}
+
+// records joins lines spanning parentheses into a single entry, comments are stripped. The bool is whether the entry
+// started with whitespace, ie it belongs to the previous owner.
+func records(scanner *bufio.Scanner) iter.Seq2[string, bool] {
+	return func(yield func(string, bool) bool) {
+		var (
+			entry     strings.Builder
+			inherited bool
+			depth     int
+		)
+		for scanner.Scan() {
+			line, delta := stripComment(scanner.Text())
+			if depth == 0 {
+				inherited = len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+			}
+			entry.WriteString(line)
+			entry.WriteByte(' ')
+
+			depth += delta
+			if depth > 0 {
+				continue
+			}
+			depth = 0
+
+			record := parens.Replace(entry.String())
+			entry.Reset()
+			if strings.TrimSpace(record) == "" {
+				continue
+			}
+			if !yield(record, inherited) {
+				return
+			}
+		}
+	}
+}
+
+func isTTL(token string) bool {
+	for _, c := range token {
+		// bind style units, eg 1h30m
+		if (c < '0' || c > '9') && !strings.ContainsRune("smhdw", c) {
+			return false
+		}
+	}
+	return token != "" && token[0] >= '0' && token[0] <= '9'
+}
+
+// recordType returns the type of a record given the fields after its owner, skipping the optional ttl and class
+func recordType(fields []string) string {
+	for _, f := range fields {
+		if _, ok := classes[f]; ok || isTTL(f) {
+			continue
+		}
+		return f
+	}
+	return ""
+}
+
+func absolute(name string, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	default:
+		return name + "." + origin
+	}
+}
+
+/** Delegations streams the names delegated directly below origin (eg "example.net" for origin "net") from a master
+ * format zone file, ie the owners of NS records. Names are lowercased without the trailing dot, and consecutive
+ * duplicates are collapsed, which for sorted files like CZDS's means every name is yielded once. $ORIGIN directives
+ * override origin, $INCLUDE isn't supported and malformed lines are skipped. Any read error is yielded once and ends
+ * the stream.
+ */
+func Delegations(r io.Reader, origin string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+		origin = strings.TrimSuffix(strings.ToLower(origin), ".")
+		owner, last := "", ""
+		for record, inherited := range records(scanner) {
+			fields := strings.Fields(strings.ToLower(record))
+
+			if strings.HasPrefix(fields[0], "$") {
+				if fields[0] == "$origin" && len(fields) > 1 {
+					origin = absolute(fields[1], origin)
+				}
+				continue
+			}
+
+			if !inherited {
+				owner, fields = absolute(fields[0], origin), fields[1:]
+			}
+			if recordType(fields) != "ns" || owner == last {
+				continue
+			}
+
+			// only direct children, the apex's own NS records and glue further down aren't delegations
+			label, parent, ok := strings.Cut(owner, ".")
+			if !ok || label == "" || parent != origin {
+				continue
+			}
+
+			last = owner
+			if !yield(owner, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package zonefile
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDelegations(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		zone   string
+		want   []string
+	}{
+		{
+			name:   "absolute and relative owners",
+			origin: "net.",
+			zone: `example.net. 172800 IN NS ns1.example.net.
+other 172800 IN NS ns1.other.net.
+@ 86400 IN NS a.gtld-servers.net.
+`,
+			want: []string{"example.net", "other.net"},
+		},
+		{
+			name:   "origin directive",
+			origin: "com",
+			zone: `$ORIGIN net.
+$TTL 86400
+example IN NS ns1.example.net.
+`,
+			want: []string{"example.net"},
+		},
+		{
+			name:   "ttl and class are optional and in either order",
+			origin: "net",
+			zone: `a NS ns1.a.net.
+b 3600 NS ns1.b.net.
+c IN NS ns1.c.net.
+d IN 1h30m NS ns1.d.net.
+e 3600 IN A 192.0.2.1
+`,
+			want: []string{"a.net", "b.net", "c.net", "d.net"},
+		},
+		{
+			name:   "inherited owner",
+			origin: "net",
+			zone: `a 3600 IN A 192.0.2.1
+	3600 IN NS ns1.a.net.
+b 3600 IN NS ns1.b.net.
+  3600 IN NS ns2.b.net.
+`,
+			want: []string{"a.net", "b.net"},
+		},
+		{
+			name:   "parentheses and comments",
+			origin: "net",
+			zone: `@ IN SOA a.gtld-servers.net. nstld.verisign-grs.com. ( ; the apex
+	1 ; serial
+	1800 900 604800 86400 )
+a IN NS ( ns1.a.net. ) ; a comment with a ( in it
+b IN TXT "not ; a comment"
+b IN NS ns1.b.net.
+`,
+			want: []string{"a.net", "b.net"},
+		},
+		{
+			name:   "consecutive duplicates are collapsed",
+			origin: "net",
+			zone: `a IN NS ns1.a.net.
+a IN NS ns2.a.net.
+A.NET. IN NS ns3.a.net.
+b IN NS ns1.b.net.
+a IN NS ns1.a.net.
+`,
+			want: []string{"a.net", "b.net", "a.net"},
+		},
+		{
+			name:   "only direct children",
+			origin: "net",
+			zone: `a IN NS ns1.a.net.
+sub.a IN NS ns1.sub.a.net.
+ns1.a IN A 192.0.2.1
+example.com. IN NS ns1.example.com.
+`,
+			want: []string{"a.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for name, err := range Delegations(strings.NewReader(tt.zone), tt.origin) {
+				if err != nil {
+					t.Fatalf("Delegations() error = %v", err)
+				}
+				got = append(got, name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Delegations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegationsReadError(t *testing.T) {
+	failed := errors.New("read failed")
+	r := iotest.DataErrReader(iotest.ErrReader(failed))
+
+	var errs []error
+	for _, err := range Delegations(r, "net") {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], failed) {
+		t.Errorf("Delegations() errors = %v, want just %v", errs, failed)
+	}
+}
@@ -0,0 +1,149 @@
+package verifydomain
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+)
+
+const (
+	defaultRDAPFloor = time.Minute
+	// successes it takes to climb from nothing to the ceiling, so the rate goes up by a hundredth of the ceiling each time
+	rdapIncreaseSteps = 100
+	// the rate is halved whenever a server pushes back
+	rdapDecreaseFactor = 0.5
+)
+
+type (
+	/** RDAPAdaptive tunes the rate of each RDAP server with AIMD, the rate goes up a little after every success and is
+	 * halved whenever the server pushes back with 429 or 503, same idea as tcp congestion control. The configured limit
+	 * of a server is only where it starts.
+	 */
+	RDAPAdaptive struct {
+		Floor time.Duration // slowest interval between requests to a server, defaults to 1 minute
+		// fastest interval between requests to a server, defaults to the fastest configured limit so the rate never
+		// climbs past what was asked for
+		Ceiling time.Duration
+
+		// Rates seeds servers with what was learned on previous runs and is kept up to date as rates change, optional
+		Rates *RDAPRates
+	}
+
+	// RDAPRates holds the learned interval between requests of each rdap server, safe for concurrent use
+	RDAPRates struct {
+		mu        sync.Mutex
+		intervals map[string]time.Duration
+	}
+)
+
+func NewRDAPRates() *RDAPRates {
+	return &RDAPRates{
+		intervals: make(map[string]time.Duration),
+	}
+}
+
+func (r *RDAPRates) Get(server string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interval, ok := r.intervals[server]
+	return interval, ok
+}
+
+func (r *RDAPRates) Set(server string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.intervals[server] = interval
+}
+
+// Entries returns a copy of every learned interval by server
+func (r *RDAPRates) Entries() map[string]time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return maps.Clone(r.intervals)
+}
+
+// interval is the time between requests at limit l, the inverse of rate.Every
+func interval(l rate.Limit) time.Duration {
+	return time.Duration(float64(time.Second) / float64(l))
+}
+
+/** throttledBy reports whether err is the server telling us to slow down, ie a 429 or a 503 with a Retry-After. A 503
+ * without one is the server being down which is the breaker's business, see downFailure.
+ */
+func throttledBy(err error) bool {
+	var se *rdapclient.StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.RateLimited() || (se.StatusCode == http.StatusServiceUnavailable && se.RetryAfter > 0)
+}
+
+// initialLimit is where a server's rate starts, what was learned before wins over the configured limit
+func (c *rdapChecker) initialLimit(server string, limit RDAPLimit) rate.Limit {
+	l := rate.Every(limit.Every)
+	if c.adaptive == nil {
+		return l
+	}
+
+	if c.adaptive.Rates != nil {
+		if learned, ok := c.adaptive.Rates.Get(server); ok && learned > 0 {
+			l = rate.Every(learned)
+		}
+	}
+	return min(max(l, c.floor), c.ceiling)
+}
+
+// succeeded nudges the server's rate up towards the ceiling
+func (c *rdapChecker) succeeded(server string, s *rdapServer) {
+	if c.adaptive == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := min(s.limiter.Limit()+c.ceiling/rdapIncreaseSteps, c.ceiling)
+	c.setLimit(server, s, l)
+}
+
+/** throttled cuts the server's rate down towards the floor. Requests already in flight tend to get pushed back together,
+ * so the rate is only cut once per interval rather than once per failed request.
+ */
+func (c *rdapChecker) throttled(server string, s *rdapServer, now time.Time) {
+	if c.adaptive == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.limiter.Limit()
+	if now.Sub(s.lastCut) < interval(current) {
+		return
+	}
+	s.lastCut = now
+
+	l := max(current*rdapDecreaseFactor, c.floor)
+	c.setLimit(server, s, l)
+}
+
+// setLimit must be called with s.mu held
+func (c *rdapChecker) setLimit(server string, s *rdapServer, l rate.Limit) {
+	if l == s.limiter.Limit() {
+		return
+	}
+
+	s.limiter.SetLimit(l)
+	if c.adaptive.Rates != nil {
+		c.adaptive.Rates.Set(server, interval(l))
+	}
+}
@@ -0,0 +1,168 @@
+package verifydomain
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const testServer = "https://rdap.example/"
+
+func newAdaptiveChecker(adaptive *RDAPAdaptive) *rdapChecker {
+	return NewRDAPChecker(nil, RDAPConfig{
+		DefaultLimit: RDAPLimit{Every: 10 * time.Second, Burst: 1},
+		Adaptive:     adaptive,
+	}).(*rdapChecker)
+}
+
+func TestInitialLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive bool
+		// what a previous run learned, if it learned anything
+		learned *time.Duration
+		limit   time.Duration
+		want    time.Duration
+	}{
+		{name: "fixed", limit: 10 * time.Millisecond, want: 10 * time.Millisecond},
+		{name: "configured", adaptive: true, limit: 10 * time.Second, want: 10 * time.Second},
+		{name: "learned wins", adaptive: true, learned: ptr(20 * time.Second), limit: 10 * time.Second, want: 20 * time.Second},
+		{name: "nothing learned", adaptive: true, learned: ptr(0), limit: 10 * time.Second, want: 10 * time.Second},
+		{name: "clamped to the ceiling", adaptive: true, learned: ptr(time.Millisecond), limit: 10 * time.Second, want: time.Second},
+		{name: "clamped to the floor", adaptive: true, learned: ptr(time.Hour), limit: 10 * time.Second, want: time.Minute},
+		{name: "configured clamped", adaptive: true, limit: 10 * time.Millisecond, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var adaptive *RDAPAdaptive
+			if tt.adaptive {
+				adaptive = &RDAPAdaptive{Floor: time.Minute, Ceiling: time.Second, Rates: NewRDAPRates()}
+				if tt.learned != nil {
+					adaptive.Rates.Set(testServer, *tt.learned)
+				}
+			}
+			c := newAdaptiveChecker(adaptive)
+
+			if got := interval(c.initialLimit(testServer, RDAPLimit{Every: tt.limit, Burst: 1})); got != tt.want {
+				t.Errorf("initialLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveDefaults(t *testing.T) {
+	c := NewRDAPChecker(nil, RDAPConfig{
+		DefaultLimit: RDAPLimit{Every: 10 * time.Second, Burst: 1},
+		Limits:       map[string]RDAPLimit{testServer: {Every: 2 * time.Second, Burst: 1}},
+		Adaptive:     &RDAPAdaptive{},
+	}).(*rdapChecker)
+
+	// never faster than anything configured
+	if got := interval(c.ceiling); got != 2*time.Second {
+		t.Errorf("ceiling = %v, want %v", got, 2*time.Second)
+	}
+	if got := interval(c.floor); got != defaultRDAPFloor {
+		t.Errorf("floor = %v, want %v", got, defaultRDAPFloor)
+	}
+
+	// a floor faster than the ceiling is raised to it
+	c = newAdaptiveChecker(&RDAPAdaptive{Floor: time.Millisecond, Ceiling: time.Second})
+	if c.floor != c.ceiling {
+		t.Errorf("floor = %v, want the ceiling %v", interval(c.floor), interval(c.ceiling))
+	}
+}
+
+func TestSucceeded(t *testing.T) {
+	rates := NewRDAPRates()
+	c := newAdaptiveChecker(&RDAPAdaptive{Floor: time.Minute, Ceiling: time.Second, Rates: rates})
+	s := c.server(testServer)
+
+	// each success adds a hundredth of the ceiling's rate
+	start := s.limiter.Limit()
+	c.succeeded(testServer, s)
+	if got, want := s.limiter.Limit(), start+c.ceiling/rdapIncreaseSteps; !closeTo(got, want) {
+		t.Errorf("rate after a success = %v, want %v", got, want)
+	}
+	if learned, _ := rates.Get(testServer); learned != interval(s.limiter.Limit()) {
+		t.Errorf("learned interval = %v, want %v", learned, interval(s.limiter.Limit()))
+	}
+
+	// up to the ceiling and no further
+	for range 2 * rdapIncreaseSteps {
+		c.succeeded(testServer, s)
+	}
+	if got := s.limiter.Limit(); got != c.ceiling {
+		t.Errorf("rate after many successes = %v, want the ceiling %v", got, c.ceiling)
+	}
+
+	// a fixed rate stays put
+	fixed := newAdaptiveChecker(nil)
+	fs := fixed.server(testServer)
+	fixed.succeeded(testServer, fs)
+	if got := interval(fs.limiter.Limit()); got != 10*time.Second {
+		t.Errorf("fixed rate after a success = %v, want %v", got, 10*time.Second)
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	rates := NewRDAPRates()
+	c := newAdaptiveChecker(&RDAPAdaptive{Floor: time.Minute, Ceiling: time.Second, Rates: rates})
+	s := c.server(testServer)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 10s -> 20s
+	c.throttled(testServer, s, now)
+	if got := interval(s.limiter.Limit()); got != 20*time.Second {
+		t.Fatalf("interval after being throttled = %v, want %v", got, 20*time.Second)
+	}
+	if learned, _ := rates.Get(testServer); learned != 20*time.Second {
+		t.Errorf("learned interval = %v, want %v", learned, 20*time.Second)
+	}
+
+	// requests which were in flight together are only cut once
+	c.throttled(testServer, s, now.Add(19*time.Second))
+	if got := interval(s.limiter.Limit()); got != 20*time.Second {
+		t.Fatalf("interval after being throttled again within the interval = %v, want %v", got, 20*time.Second)
+	}
+
+	// 20s -> 40s once the interval has passed
+	now = now.Add(20 * time.Second)
+	c.throttled(testServer, s, now)
+	if got := interval(s.limiter.Limit()); got != 40*time.Second {
+		t.Fatalf("interval after being throttled after the interval = %v, want %v", got, 40*time.Second)
+	}
+
+	// 40s -> the 1m floor rather than 80s
+	now = now.Add(40 * time.Second)
+	c.throttled(testServer, s, now)
+	if got := interval(s.limiter.Limit()); got != time.Minute {
+		t.Fatalf("interval after being throttled past the floor = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestRDAPRatesSeedTheNextRun(t *testing.T) {
+	rates := NewRDAPRates()
+	c := newAdaptiveChecker(&RDAPAdaptive{Floor: time.Minute, Ceiling: time.Second, Rates: rates})
+	c.throttled(testServer, c.server(testServer), time.Now())
+
+	// what's persisted at the end of a run is restored at the start of the next
+	restored := NewRDAPRates()
+	for server, learned := range rates.Entries() {
+		restored.Set(server, learned)
+	}
+	next := newAdaptiveChecker(&RDAPAdaptive{Floor: time.Minute, Ceiling: time.Second, Rates: restored})
+	if got := interval(next.server(testServer).limiter.Limit()); got != 20*time.Second {
+		t.Errorf("next run starts at %v, want %v", got, 20*time.Second)
+	}
+}
+
+func ptr(d time.Duration) *time.Duration {
+	return &d
+}
+
+// closeTo compares rates which went through float arithmetic
+func closeTo(a, b rate.Limit) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,184 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openrdap/rdap"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+var RDAPCircuitOpenError = errors.New("verifydomain: rdap server circuit open")
+
+/** CircuitOpenError is returned for domains whose rdap server's breaker is open, Until is when the server may be
+ * tried again so the domain can be deferred until then. It matches RDAPCircuitOpenError with errors.Is.
+ */
+type CircuitOpenError struct {
+	Server string
+	Until  time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v: %s until %s", RDAPCircuitOpenError, e.Server, e.Until.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return RDAPCircuitOpenError
+}
+
+type (
+	breakerState string
+
+	/** breaker is a circuit breaker for a single rdap server. It trips open after threshold failures in a row, while
+	 * open nothing is sent to the server until the cooldown runs out, then a single probe request is let through
+	 * (half-open) which closes the breaker again if it succeeds or reopens it if it doesn't.
+	 */
+	breaker struct {
+		threshold int
+		cooldown  time.Duration
+
+		mu        sync.Mutex
+		state     breakerState
+		failures  int
+		openUntil time.Time
+		probing   bool // a half-open probe is in flight
+	}
+)
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half-open"
+)
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+}
+
+// blocked reports whether a request would be turned away right now, without claiming the probe
+func (b *breaker) blocked(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		return now.Before(b.openUntil)
+	case breakerHalfOpen:
+		return b.probing
+	default:
+		return false
+	}
+}
+
+/** retryAt is when a request turned away right now could next get through, the end of the cooldown while open. While
+ * half-open the probe decides, if it fails the breaker is open for another cooldown so that's as long as it may take.
+ */
+func (b *breaker) retryAt(now time.Time) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		return b.openUntil
+	case breakerHalfOpen:
+		return now.Add(b.cooldown)
+	default:
+		return now
+	}
+}
+
+// allow reports whether a request may be sent, once the cooldown is over the first caller gets to be the probe
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state, b.probing = breakerHalfOpen, true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// succeeded closes the breaker, returning the state it was in
+func (b *breaker) succeeded() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from := b.state
+	b.state, b.failures, b.probing = breakerClosed, 0, false
+	return from
+}
+
+// failed counts a failure and trips the breaker if need be, returning whether it opened because of it
+func (b *breaker) failed(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	switch {
+	case b.state == breakerHalfOpen, b.state == breakerClosed && b.failures >= b.threshold:
+		b.state, b.probing = breakerOpen, false
+		b.openUntil = now.Add(b.cooldown)
+		return true
+	default:
+		return false
+	}
+}
+
+// released gives up the probe without an outcome, eg the caller gave up waiting, so the next request probes instead
+func (b *breaker) released() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+/** downFailure reports whether err means the server itself is down or broken rather than it turning us away, ie it
+ * couldn't be reached at all or answered with a 5xx. Any 4xx is an answer from a server which is up. A 503 with a
+ * Retry-After is the server asking for a break which pacing takes care of, so it doesn't count towards the breaker as
+ * well.
+ */
+func downFailure(err error) bool {
+	if throttledBy(err) {
+		return false
+	}
+
+	var se *rdapclient.StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= http.StatusInternalServerError
+	}
+
+	// without a status nothing usable came back, eg the connection was refused or timed out
+	var ce *rdap.ClientError
+	if errors.As(err, &ce) {
+		return ce.Type == rdap.NoWorkingServers
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, context.DeadlineExceeded)
+}
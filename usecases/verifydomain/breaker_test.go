@@ -0,0 +1,140 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openrdap/rdap"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+)
+
+func TestBreakerStateMachine(t *testing.T) {
+	const threshold, cooldown = 3, time.Minute
+	b := newBreaker(threshold, cooldown)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	expect := func(step string, state breakerState, blocked bool) {
+		t.Helper()
+		if b.state != state {
+			t.Fatalf("%s: state = %s, want %s", step, b.state, state)
+		}
+		if got := b.blocked(now); got != blocked {
+			t.Fatalf("%s: blocked() = %v, want %v", step, got, blocked)
+		}
+	}
+
+	// closed, failures short of the threshold don't trip it
+	for i := 1; i < threshold; i++ {
+		if !b.allow(now) {
+			t.Fatalf("closed breaker turned away request %d", i)
+		}
+		if b.failed(now) {
+			t.Fatalf("breaker opened after %d failures, want %d", i, threshold)
+		}
+	}
+	expect("below threshold", breakerClosed, false)
+
+	// closed -> open
+	if !b.failed(now) {
+		t.Fatal("breaker didn't open at the threshold")
+	}
+	expect("at threshold", breakerOpen, true)
+	if b.allow(now.Add(cooldown - time.Second)) {
+		t.Fatal("open breaker let a request through before the cooldown ran out")
+	}
+	if got := b.retryAt(now); !got.Equal(now.Add(cooldown)) {
+		t.Fatalf("open retryAt() = %v, want %v", got, now.Add(cooldown))
+	}
+
+	// open -> half-open once the cooldown runs out, with a single probe
+	now = now.Add(cooldown)
+	expect("cooldown over", breakerOpen, false)
+	if !b.allow(now) {
+		t.Fatal("no probe allowed once the cooldown ran out")
+	}
+	expect("probing", breakerHalfOpen, true)
+	if b.allow(now) {
+		t.Fatal("a second probe was allowed while the first is in flight")
+	}
+	if got := b.retryAt(now); !got.Equal(now.Add(cooldown)) {
+		t.Fatalf("half-open retryAt() = %v, want %v", got, now.Add(cooldown))
+	}
+
+	// a probe given up on hands the probe to the next request
+	b.released()
+	expect("probe released", breakerHalfOpen, false)
+	if !b.allow(now) {
+		t.Fatal("no probe allowed after the last one was released")
+	}
+
+	// half-open -> open when the probe fails, for another full cooldown
+	if !b.failed(now) {
+		t.Fatal("failed probe didn't reopen the breaker")
+	}
+	expect("probe failed", breakerOpen, true)
+	if got := b.retryAt(now); !got.Equal(now.Add(cooldown)) {
+		t.Fatalf("reopened retryAt() = %v, want %v", got, now.Add(cooldown))
+	}
+
+	// half-open -> closed when the probe succeeds
+	now = now.Add(cooldown)
+	if !b.allow(now) {
+		t.Fatal("no probe allowed once the second cooldown ran out")
+	}
+	if from := b.succeeded(); from != breakerHalfOpen {
+		t.Fatalf("succeeded() from = %s, want %s", from, breakerHalfOpen)
+	}
+	expect("probe succeeded", breakerClosed, false)
+
+	// and the failures start counting from scratch
+	for i := 1; i < threshold; i++ {
+		if b.failed(now) {
+			t.Fatalf("closed breaker reopened after %d failures, want %d", i, threshold)
+		}
+	}
+}
+
+func TestFailureSignals(t *testing.T) {
+	status := func(code int, retryAfter time.Duration) error {
+		return &rdapclient.StatusError{Server: "https://rdap.example/", StatusCode: code, RetryAfter: retryAfter, Err: errors.New("test")}
+	}
+
+	// each failure feeds either the adaptive rate or the breaker, never both
+	tests := []struct {
+		name      string
+		err       error
+		throttled bool
+		down      bool
+	}{
+		{name: "429", err: status(http.StatusTooManyRequests, 0), throttled: true},
+		{name: "429 with retry after", err: status(http.StatusTooManyRequests, time.Second), throttled: true},
+		{name: "503", err: status(http.StatusServiceUnavailable, 0), down: true},
+		{name: "503 with retry after", err: status(http.StatusServiceUnavailable, time.Second), throttled: true},
+		{name: "500", err: status(http.StatusInternalServerError, 0), down: true},
+		{name: "wrapped 502", err: fmt.Errorf("wrapped: %w", status(http.StatusBadGateway, 0)), down: true},
+		{name: "400", err: status(http.StatusBadRequest, 0)},
+		{name: "403", err: status(http.StatusForbidden, 0)},
+		{name: "unreachable", err: &rdap.ClientError{Type: rdap.NoWorkingServers}, down: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, down: true},
+		{name: "timeout", err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), down: true},
+		{name: "bad input", err: &rdap.ClientError{Type: rdap.InputError}},
+		{name: "wrong response type", err: &rdap.ClientError{Type: rdap.WrongResponseType}},
+		{name: "unsupported", err: &rdap.ClientError{Type: rdap.BootstrapNoMatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttledBy(tt.err); got != tt.throttled {
+				t.Errorf("throttledBy() = %v, want %v", got, tt.throttled)
+			}
+			if got := downFailure(tt.err); got != tt.down {
+				t.Errorf("downFailure() = %v, want %v", got, tt.down)
+			}
+		})
+	}
+}
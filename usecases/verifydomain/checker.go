@@ -0,0 +1,85 @@
+package verifydomain
+
+import (
+	"context"
+
+	"github.com/khinshankhan/jitter-go/v2"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+type (
+	/** Checker is a single stage of verification, eg a zone file, DNS or RDAP. Checkers are chained in the order given
+	 * to New, each one short-circuits the chain with a definitive result and otherwise passes the domain on to the
+	 * next. Returning an error stops the chain unless it's classified as unsupported, ie the checker has nothing to
+	 * say about the domain rather than failing to say it.
+	 */
+	Checker interface {
+		// Source is recorded as the provenance of results the checker produces
+		Source() domaincheck.Source
+		Check(ctx context.Context, domainName string) (Result, error)
+	}
+
+	// Verdict is what a checker concluded about a domain
+	Verdict string
+
+	// Confidence is how far a verdict can be trusted
+	Confidence string
+
+	Result struct {
+		Verdict    Verdict
+		Confidence Confidence
+
+		Attempts int    // queries made to reach the verdict
+		Server   string // server which gave the verdict, only recorded for rdap for now
+
+		// Registration is what the registry says about a taken domain, only rdap fills it in
+		Registration *rdapclient.Registration
+	}
+)
+
+const (
+	VerdictTaken     Verdict = "taken"
+	VerdictAvailable Verdict = "available"
+	// VerdictReserved means the registry is holding the name back, eg premium or reserved names
+	VerdictReserved Verdict = "reserved"
+	// VerdictUnknown means the checker couldn't tell either way
+	VerdictUnknown Verdict = "unknown"
+)
+
+const (
+	// ConfidenceDefinitive verdicts end the chain
+	ConfidenceDefinitive Confidence = "definitive"
+	// ConfidenceTentative verdicts are only used if no later checker does better
+	ConfidenceTentative Confidence = "tentative"
+)
+
+func (v Verdict) availability() domaincheck.Availability {
+	switch v {
+	case VerdictTaken:
+		return domaincheck.AvailabilityRegistered
+	case VerdictAvailable:
+		return domaincheck.AvailabilityAvailable
+	case VerdictReserved:
+		return domaincheck.AvailabilityReserved
+	default:
+		return domaincheck.AvailabilityUnknown
+	}
+}
+
+type backoffKey struct{}
+
+// withBackoff hands checkers which retry the caller's backoff strategy, the Raw usecases take one so retries can be
+// made deterministic
+func withBackoff(ctx context.Context, backoffStrategy jitter.Strategy) context.Context {
+	return context.WithValue(ctx, backoffKey{}, backoffStrategy)
+}
+
+// backoffFrom returns the backoff strategy of the verification, or a fresh one if there isn't any
+func backoffFrom(ctx context.Context) jitter.Strategy {
+	if backoffStrategy, ok := ctx.Value(backoffKey{}).(jitter.Strategy); ok {
+		return backoffStrategy
+	}
+	return newRandomBackoff()
+}
@@ -0,0 +1,40 @@
+package verifydomain
+
+import (
+	"context"
+
+	"github.com/khinshankhan/nomex/adapters/dnsresolver"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+type dnsChecker struct {
+	dnsResolver *dnsresolver.Resolver
+}
+
+// NewDNSChecker checks domains over DNS, which can be trusted when it says a domain is taken but not otherwise since
+// registered domains needn't be delegated
+func NewDNSChecker(dnsResolver *dnsresolver.Resolver) Checker {
+	return dnsChecker{dnsResolver: dnsResolver}
+}
+
+func (c dnsChecker) Source() domaincheck.Source {
+	return domaincheck.SourceDNS
+}
+
+func (c dnsChecker) Check(ctx context.Context, domainName string) (Result, error) {
+	status, err := c.dnsResolver.Lookup(ctx, domainName)
+	if err != nil {
+		return Result{Verdict: VerdictUnknown, Attempts: 1}, err
+	}
+
+	switch {
+	case status.Taken():
+		return Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Attempts: 1}, nil
+	case status == dnsresolver.StatusServFail:
+		// the name couldn't be resolved, which says nothing about whether it's registered (lame delegations are common
+		// for registered domains) so it's left to the rest of the chain
+		return Result{Verdict: VerdictUnknown, Confidence: ConfidenceTentative, Attempts: 1}, nil
+	default:
+		return Result{Verdict: VerdictAvailable, Confidence: ConfidenceTentative, Attempts: 1}, nil
+	}
+}
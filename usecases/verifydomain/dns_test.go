@@ -0,0 +1,87 @@
+package verifydomain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/adapters/dnsresolver"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+// cachedResolver answers from its cache alone, the nameserver it's given is never asked
+func cachedResolver(t *testing.T, statuses map[string]dnsresolver.Status) *dnsresolver.Resolver {
+	t.Helper()
+
+	cache := dnsresolver.NewCache(len(statuses))
+	for domain, status := range statuses {
+		cache.Put(domain, status, time.Now().Add(time.Hour))
+	}
+	r, err := dnsresolver.New(dnsresolver.Config{
+		Timeout:     time.Second,
+		Nameservers: []string{"127.0.0.1:1"},
+		Cache:       cache,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestDNSChecker(t *testing.T) {
+	tests := []struct {
+		status dnsresolver.Status
+		want   Result
+	}{
+		{dnsresolver.StatusDelegated, Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Attempts: 1}},
+		{dnsresolver.StatusExists, Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Attempts: 1}},
+		{dnsresolver.StatusReferral, Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Attempts: 1}},
+		{dnsresolver.StatusNXDomain, Result{Verdict: VerdictAvailable, Confidence: ConfidenceTentative, Attempts: 1}},
+		{dnsresolver.StatusServFail, Result{Verdict: VerdictUnknown, Confidence: ConfidenceTentative, Attempts: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			c := NewDNSChecker(cachedResolver(t, map[string]dnsresolver.Status{"example.test": tt.status}))
+			got, err := c.Check(context.Background(), "example.test")
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got.Verdict != tt.want.Verdict || got.Confidence != tt.want.Confidence || got.Attempts != tt.want.Attempts {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDomainDNSServFail(t *testing.T) {
+	dns := NewDNSChecker(cachedResolver(t, map[string]dnsresolver.Status{"example.test": dnsresolver.StatusServFail}))
+
+	tests := []struct {
+		name     string
+		checkers []Checker
+		want     domaincheck.Availability
+	}{
+		{
+			// rather than tentatively available
+			name:     "unknown on its own",
+			checkers: []Checker{dns},
+			want:     domaincheck.AvailabilityUnknown,
+		},
+		{
+			name:     "overruled by a later definitive answer",
+			checkers: []Checker{dns, fakeChecker{source: domaincheck.SourceRDAP, result: Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive}, asked: &[]domaincheck.Source{}}},
+			want:     domaincheck.AvailabilityRegistered,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &usecases{checkers: tt.checkers}
+
+			var prov provenance
+			got, err := u.checkDomain(context.Background(), "example.test", &prov)
+			if err != nil || got != tt.want {
+				t.Errorf("checkDomain() = %q, %v, want %q, nil", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/khinshankhan/nomex/adapters/eppclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+// registries word these differently but a reason along these lines means the registry is holding on to the name rather
+// than someone having registered it
+var reservedReason = regexp.MustCompile(`(?i)reserved|premium|restricted|blocked|prohibited|not available for registration`)
+
+// EPPUnsupportedTLDError means the registrar account doesn't serve the domain's tld, so there's no registry to ask
+var EPPUnsupportedTLDError = errors.New("verifydomain: tld isn't served by the epp account")
+
+type eppChecker struct {
+	eppClient *eppclient.Client
+	tlds      map[string]struct{}
+}
+
+/** NewEPPChecker checks domains with EPP domain:check through a registrar account, the most authoritative answer there
+ * is. An account only talks to a single registry so tlds are the ones it serves, domains under any other tld are
+ * unsupported rather than asked of a registry which would turn them away as not available.
+ */
+func NewEPPChecker(eppClient *eppclient.Client, tlds []string) Checker {
+	served := make(map[string]struct{}, len(tlds))
+	for _, tld := range tlds {
+		served[strings.Trim(strings.ToLower(tld), ".")] = struct{}{}
+	}
+	return eppChecker{eppClient: eppClient, tlds: served}
+}
+
+func (c eppChecker) Source() domaincheck.Source {
+	return domaincheck.SourceEPP
+}
+
+func (c eppChecker) Check(ctx context.Context, domainName string) (Result, error) {
+	result := Result{Verdict: VerdictUnknown, Attempts: 1}
+	name := strings.TrimSuffix(strings.ToLower(domainName), ".")
+	tld := name[strings.LastIndex(name, ".")+1:]
+	if _, ok := c.tlds[tld]; !ok {
+		return Result{Verdict: VerdictUnknown}, fmt.Errorf("%w: %s", EPPUnsupportedTLDError, tld)
+	}
+
+	checked, err := c.eppClient.Check(ctx, domainName)
+	if err != nil {
+		return result, err
+	}
+
+	switch {
+	case checked.Available:
+		result.Verdict = VerdictAvailable
+	case reservedReason.MatchString(checked.Reason):
+		result.Verdict = VerdictReserved
+	default:
+		result.Verdict = VerdictTaken
+	}
+	result.Confidence = ConfidenceDefinitive
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+func TestReservedReason(t *testing.T) {
+	tests := map[string]bool{
+		"Premium name":                   true,
+		"Reserved by registry":           true,
+		"Restricted":                     true,
+		"Blocked (DPML)":                 true,
+		"Registration prohibited":        true,
+		"Not available for registration": true,
+		"In use":                         false,
+		"Domain exists":                  false,
+		"":                               false,
+	}
+	for reason, want := range tests {
+		if got := reservedReason.MatchString(reason); got != want {
+			t.Errorf("reservedReason.MatchString(%q) = %v, want %v", reason, got, want)
+		}
+	}
+}
+
+func TestEPPCheckerOnlyAsksForItsTLDs(t *testing.T) {
+	// without a client, anything that gets as far as asking the registry panics
+	c := NewEPPChecker(nil, []string{"NET", ".com"})
+
+	for _, domain := range []string{"example.org", "example.net.org", "EXAMPLE.IO."} {
+		_, err := c.Check(context.Background(), domain)
+		if !errors.Is(err, EPPUnsupportedTLDError) {
+			t.Errorf("Check(%q) error = %v, want %v", domain, err, EPPUnsupportedTLDError)
+		}
+		if got := classifyError(err); got != domaincheck.ErrorClassUnsupported {
+			t.Errorf("Check(%q) error class = %q, want %q", domain, got, domaincheck.ErrorClassUnsupported)
+		}
+	}
+
+	served := c.(eppChecker).tlds
+	for _, tld := range []string{"net", "com"} {
+		if _, ok := served[tld]; !ok {
+			t.Errorf("tld %q isn't served, want it served", tld)
+		}
+	}
+}
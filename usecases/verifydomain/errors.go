@@ -0,0 +1,107 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/openrdap/rdap"
+
+	"github.com/khinshankhan/nomex/adapters/eppclient"
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+	"github.com/khinshankhan/nomex/adapters/whoisclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+// classifyError maps an error from any of the checkers onto an ErrorClass
+func classifyError(err error) domaincheck.ErrorClass {
+	if errors.Is(err, LimiterBurstError) {
+		return domaincheck.ErrorClassInternal
+	}
+	if errors.Is(err, RDAPCircuitOpenError) {
+		return domaincheck.ErrorClassUnavailable
+	}
+
+	// the http status says more than the rdap error it wraps
+	var se *rdapclient.StatusError
+	if errors.As(err, &se) {
+		switch {
+		case se.RateLimited():
+			return domaincheck.ErrorClassRateLimited
+		case se.StatusCode == http.StatusServiceUnavailable:
+			return domaincheck.ErrorClassUnavailable
+		default:
+			return domaincheck.ErrorClassUpstream
+		}
+	}
+
+	// typed rdap errors next
+	var ce *rdap.ClientError
+	if errors.As(err, &ce) {
+		switch ce.Type {
+		// lowkey I don't really know what all these codes mean in practice, but this is my best guess
+		case rdap.InputError:
+			return domaincheck.ErrorClassInput
+		// no match means the tld has no rdap server at all, which is as unsupported as it gets
+		case rdap.BootstrapNotSupported, rdap.BootstrapNoMatch:
+			return domaincheck.ErrorClassUnsupported
+		case rdap.WrongResponseType, rdap.RDAPServerError:
+			return domaincheck.ErrorClassUpstream
+		case rdap.NoWorkingServers:
+			return domaincheck.ErrorClassUnavailable
+		default:
+			return domaincheck.ErrorClassUpstream
+		}
+	}
+
+	var re *eppclient.ResultError
+	if errors.As(err, &re) {
+		if re.RateLimited() {
+			return domaincheck.ErrorClassRateLimited
+		}
+		return domaincheck.ErrorClassUpstream
+	}
+
+	// whois and epp errors are plain sentinels
+	switch {
+	case errors.Is(err, whoisclient.WhoisNoServerError), errors.Is(err, EPPUnsupportedTLDError):
+		return domaincheck.ErrorClassUnsupported
+	case errors.Is(err, whoisclient.WhoisRateLimitedError):
+		return domaincheck.ErrorClassRateLimited
+	case errors.Is(err, whoisclient.WhoisUnparsableError):
+		return domaincheck.ErrorClassUpstream
+	case errors.Is(err, eppclient.EPPUnexpectedResponseError), errors.Is(err, eppclient.EPPFrameSizeError):
+		return domaincheck.ErrorClassUpstream
+	}
+
+	// context classification next (to avoid being masked by *url.Error -> net.Error)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domaincheck.ErrorClassTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return domaincheck.ErrorClassCanceled
+	}
+
+	// *net.DNSError is also a net.Error so it needs to be checked first
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return domaincheck.ErrorClassTimeout
+		}
+		return domaincheck.ErrorClassDNS
+	}
+
+	// network errors = service unavailable / gateway timeout
+	var ne net.Error
+	if errors.As(err, &ne) {
+		if ne.Timeout() {
+			return domaincheck.ErrorClassTimeout
+		}
+		// temporary connect/ reset/ dns hiccup etc
+		return domaincheck.ErrorClassUnavailable
+	}
+
+	// unknown error, treat as upstream error because clearly we didn't cause it (probably)
+	return domaincheck.ErrorClassUpstream
+}
@@ -0,0 +1,394 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+var LimiterBurstError = errors.New("verifydomain: limiter burst too small")
+
+type (
+	// RDAPLimit is a token bucket, one request every Every with bursts of up to Burst requests
+	RDAPLimit struct {
+		Every time.Duration
+		Burst int
+	}
+
+	RDAPConfig struct {
+		MaxAttempts int // defaults to 5
+
+		// a server's breaker opens after BreakerThreshold failures in a row and stays open for BreakerCooldown, defaults
+		// to 5 failures and 1 minute
+		BreakerThreshold int
+		BreakerCooldown  time.Duration
+
+		// DefaultLimit applies to each server without its own limit, defaults to 1 request every 15 seconds with bursts
+		// of 5
+		DefaultLimit RDAPLimit
+		// Limits by rdap base url as bootstrapped, eg "https://rdap.verisign.com/net/v1/"
+		Limits map[string]RDAPLimit
+
+		// Adaptive adjusts the limits to how servers respond, nil keeps them fixed
+		Adaptive *RDAPAdaptive
+	}
+
+	rdapChecker struct {
+		rdapClient *rdapclient.Client
+
+		rdapMaxAttempts int
+
+		breakerThreshold int
+		breakerCooldown  time.Duration
+
+		defaultLimit RDAPLimit
+		limits       map[string]RDAPLimit
+
+		adaptive       *RDAPAdaptive
+		floor, ceiling rate.Limit
+
+		// created on first use so every server gets its own budget
+		serversMu sync.Mutex
+		servers   map[string]*rdapServer
+	}
+
+	// rdapServer is what we know about a single rdap server
+	rdapServer struct {
+		limiter *rate.Limiter
+		breaker *breaker
+
+		mu sync.Mutex
+		// set from Retry-After, nothing is sent to the server before then
+		pausedUntil time.Time
+		// last time the adaptive rate was cut
+		lastCut time.Time
+	}
+)
+
+var defaultRDAPLimit = RDAPLimit{Every: 15 * time.Second, Burst: 5}
+
+/** NewRDAPChecker checks domains over RDAP, the registry's own answer, retrying transient failures. Each RDAP server
+ * is rate limited separately so checking domains of different registries doesn't eat into a single shared budget.
+ */
+func NewRDAPChecker(rdapClient *rdapclient.Client, cfg RDAPConfig) Checker {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	breakerThreshold := cfg.BreakerThreshold
+	if breakerThreshold <= 0 {
+		breakerThreshold = defaultBreakerThreshold
+	}
+	breakerCooldown := cfg.BreakerCooldown
+	if breakerCooldown <= 0 {
+		breakerCooldown = defaultBreakerCooldown
+	}
+	defaultLimit := cfg.DefaultLimit
+	if defaultLimit.Every <= 0 || defaultLimit.Burst <= 0 {
+		defaultLimit = defaultRDAPLimit
+	}
+
+	c := &rdapChecker{
+		rdapClient: rdapClient,
+
+		rdapMaxAttempts: maxAttempts,
+
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+
+		defaultLimit: defaultLimit,
+		limits:       cfg.Limits,
+
+		servers: make(map[string]*rdapServer),
+	}
+
+	if cfg.Adaptive != nil {
+		adaptive := *cfg.Adaptive
+		if adaptive.Floor <= 0 {
+			adaptive.Floor = defaultRDAPFloor
+		}
+		if adaptive.Ceiling <= 0 {
+			adaptive.Ceiling = defaultLimit.Every
+			for _, limit := range cfg.Limits {
+				if limit.Every > 0 {
+					adaptive.Ceiling = min(adaptive.Ceiling, limit.Every)
+				}
+			}
+		}
+		// a floor faster than the ceiling makes no sense, the ceiling wins
+		adaptive.Floor = max(adaptive.Floor, adaptive.Ceiling)
+
+		c.adaptive = &adaptive
+		c.floor = rate.Every(adaptive.Floor)
+		c.ceiling = rate.Every(adaptive.Ceiling)
+	}
+	return c
+}
+
+func (c *rdapChecker) Source() domaincheck.Source {
+	return domaincheck.SourceRDAP
+}
+
+func (c *rdapChecker) server(server string) *rdapServer {
+	c.serversMu.Lock()
+	defer c.serversMu.Unlock()
+
+	if s, ok := c.servers[server]; ok {
+		return s
+	}
+
+	limit, ok := c.limits[server]
+	if !ok {
+		limit = c.defaultLimit
+	}
+	s := &rdapServer{
+		limiter: rate.NewLimiter(c.initialLimit(server, limit), limit.Burst),
+		breaker: newBreaker(c.breakerThreshold, c.breakerCooldown),
+	}
+	c.servers[server] = s
+	return s
+}
+
+// pause holds off every request to the server until the given time, a later pause wins over an earlier one
+func (s *rdapServer) pause(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+}
+
+// pausedFor is how long until the server may be asked again
+func (s *rdapServer) pausedFor(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return max(s.pausedUntil.Sub(now), 0)
+}
+
+// recordOutcome feeds the result of a request to the server's breaker
+func (c *rdapChecker) recordOutcome(ctx context.Context, server string, s *rdapServer, err error) {
+	logger := logx.GetDefaultLogger()
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// we gave up, that says nothing about the server
+		s.breaker.released()
+	case err != nil && downFailure(err):
+		if s.breaker.failed(time.Now()) {
+			logger.Warn("rdap server looks down, deferring its domains",
+				fields.String("server", server),
+				fields.Duration("cooldown", c.breakerCooldown),
+				fields.Error(err),
+			)
+		}
+	default:
+		// any answer at all, even being turned away, means the server is up
+		if from := s.breaker.succeeded(); from != breakerClosed {
+			logger.Info("rdap server is back",
+				fields.String("server", server),
+			)
+		}
+	}
+}
+
+// sleepCtx waits for d unless ctx is done first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PaceKey groups domains by the rdap server they'd be sent to, "" if there isn't one
+func (c *rdapChecker) PaceKey(ctx context.Context, domainName string) string {
+	server, err := c.rdapClient.ServerFor(ctx, domainName)
+	if err != nil {
+		return ""
+	}
+	return server
+}
+
+// PaceDelay is how long until the server has capacity for another request
+func (c *rdapChecker) PaceDelay(key string, now time.Time) time.Duration {
+	if key == "" {
+		return 0
+	}
+
+	s := c.server(key)
+	paused := s.pausedFor(now)
+	tokens := s.limiter.TokensAt(now)
+	if tokens >= 1 {
+		return paused
+	}
+	return max(paused, time.Duration((1-tokens)/float64(s.limiter.Limit())*float64(time.Second)))
+}
+
+func (c *rdapChecker) Check(ctx context.Context, domainName string) (Result, error) {
+	var result Result
+	registration, err := c.rdapWithRetry(ctx, domainName, &result)
+	switch {
+	case err != nil:
+		result.Verdict = VerdictUnknown
+		return result, err
+	case registration != nil:
+		result.Verdict = VerdictTaken
+		result.Registration = registration
+	default:
+		result.Verdict = VerdictAvailable
+	}
+	result.Confidence = ConfidenceDefinitive
+	return result, nil
+}
+
+func shouldRetryRDAP(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// respect caller context: if the context is done, don't keep retrying locally.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// rate limits and upstream/server/transient conditions are worth another go, transport layer hiccups (dns lookup
+	// timeout, tcp reset, etc) are generally retryable too.
+	switch classifyError(err) {
+	case domaincheck.ErrorClassRateLimited,
+		domaincheck.ErrorClassUpstream,
+		domaincheck.ErrorClassUnavailable,
+		domaincheck.ErrorClassTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *rdapChecker) rdapWithRetry(
+	ctx context.Context,
+	domain string,
+	result *Result,
+) (*rdapclient.Registration, error) {
+	logger := logx.GetDefaultLogger()
+	backoffStrategy := backoffFrom(ctx)
+
+	// the server decides which budget the requests come out of
+	server, err := c.rdapClient.ServerFor(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	result.Server = server
+	budget := c.server(server)
+	limiter := budget.limiter
+
+	var lastErr error
+
+	for attempt := 0; attempt < c.rdapMaxAttempts; attempt++ {
+		// the server is down, defer the domain rather than spending retries (and the domain's timeout) on it
+		if now := time.Now(); budget.breaker.blocked(now) {
+			return nil, &CircuitOpenError{Server: server, Until: budget.breaker.retryAt(now)}
+		}
+
+		// the server asked for a break, give up now if it lasts longer than we have
+		if paused := budget.pausedFor(time.Now()); paused > 0 {
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(paused).After(deadline) {
+				return nil, context.DeadlineExceeded
+			}
+			if err := sleepCtx(ctx, paused); err != nil {
+				return nil, err
+			}
+		}
+
+		// reserve token and check the delay against ctx deadline
+		r := limiter.Reserve()
+		if !r.OK() {
+			return nil, LimiterBurstError
+		}
+		delay := r.DelayFrom(time.Now())
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			r.Cancel()
+			return nil, context.DeadlineExceeded
+		}
+
+		// wait for token or ctx cancel
+		tokenT := time.NewTimer(delay)
+		select {
+		case <-tokenT.C:
+		case <-ctx.Done():
+			tokenT.Stop()
+			r.Cancel()
+			return nil, ctx.Err()
+		}
+		// r capacity is consumed here because we proceeded.
+
+		// someone else may have claimed the half-open probe while we waited
+		if now := time.Now(); !budget.breaker.allow(now) {
+			return nil, &CircuitOpenError{Server: server, Until: budget.breaker.retryAt(now)}
+		}
+		registration, reached, err := c.rdapClient.Lookup(ctx, domain)
+		result.Attempts++
+		if reached != "" {
+			result.Server = reached
+		}
+		lastErr = err
+		switch {
+		case err == nil:
+			c.succeeded(server, budget)
+		case throttledBy(err):
+			c.throttled(server, budget, time.Now())
+		}
+		c.recordOutcome(ctx, server, budget, err)
+		if !shouldRetryRDAP(err) {
+			return registration, err
+		}
+
+		logger.Warn("rdap check failed, will retry",
+			fields.String("domain", domain),
+			fields.Int("attempt", attempt+1),
+			fields.String("error_class", string(classifyError(err))),
+			fields.Error(err),
+		)
+
+		// the server said exactly how long to back off for, which goes for every domain it serves so the whole server
+		// is paused rather than just this domain
+		var se *rdapclient.StatusError
+		if errors.As(err, &se) && se.RetryAfter > 0 {
+			logger.Info("rdap server asked us to back off",
+				fields.String("server", se.Server),
+				fields.Int("status", se.StatusCode),
+				fields.Duration("retry_after", se.RetryAfter),
+			)
+			budget.pause(time.Now().Add(se.RetryAfter))
+			continue
+		}
+
+		// use jittered delay exponentially scaled by number of failed attempts.
+		// attempt 0 should still wait a tiny bit to avoid stampedes.
+		sleepMs := backoffStrategy.Next(attempt)
+		sleep := time.Duration(sleepMs) * time.Millisecond
+		if err := sleepCtx(ctx, sleep); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Warn("rdap retries exhausted",
+		fields.String("domain", domain),
+		fields.Int("attempts", c.rdapMaxAttempts),
+		fields.Error(lastErr),
+	)
+	return nil, lastErr
+}
@@ -0,0 +1,127 @@
+package verifydomain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+)
+
+/** rdapStub is a bootstrap registry and rdap server in one. The first query for a domain is turned away with a 429
+ * and the Retry-After retryAfter gives for it and when it arrived, every query after that says the domain isn't
+ * registered.
+ */
+type rdapStub struct {
+	srv        *httptest.Server
+	retryAfter func(domain string, at time.Time) string
+
+	mu      sync.Mutex
+	queries map[string][]time.Time
+}
+
+func startRDAPStub(t *testing.T, retryAfter func(domain string, at time.Time) string) *rdapStub {
+	t.Helper()
+
+	s := &rdapStub{retryAfter: retryAfter, queries: make(map[string][]time.Time)}
+	mux := http.NewServeMux()
+	s.srv = httptest.NewServer(mux)
+	t.Cleanup(s.srv.Close)
+
+	mux.HandleFunc("/bootstrap/dns.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version":"1.0","publication":"2026-01-01T00:00:00Z","services":[[["test"],["%s/rdap/"]]]}`, s.srv.URL)
+	})
+	mux.HandleFunc("/rdap/domain/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		now := time.Now()
+		s.mu.Lock()
+		s.queries[name] = append(s.queries[name], now)
+		first := len(s.queries[name]) == 1
+		s.mu.Unlock()
+
+		if first {
+			w.Header().Set("Retry-After", s.retryAfter(name, now))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return s
+}
+
+func (s *rdapStub) queried(domain string) []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[domain]
+}
+
+func (s *rdapStub) client(t *testing.T) *rdapclient.Client {
+	t.Helper()
+
+	c, err := rdapclient.New(rdapclient.Config{
+		BootstrapURL:      s.srv.URL + "/bootstrap/",
+		BootstrapCacheDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRDAPWithRetryPausesForRetryAfter(t *testing.T) {
+	// http dates are whole seconds so this is between one and two seconds out
+	dateFor := func(turnedAway time.Time) time.Time { return turnedAway.Add(2 * time.Second).Truncate(time.Second) }
+	stub := startRDAPStub(t, func(domain string, at time.Time) string {
+		if domain == "date.test" {
+			return dateFor(at).UTC().Format(http.TimeFormat)
+		}
+		return "1"
+	})
+
+	tests := []struct {
+		domain string
+		// until when the server should be left alone, given when it turned us away
+		wantPause func(turnedAway time.Time) time.Time
+	}{
+		{domain: "seconds.test", wantPause: func(turnedAway time.Time) time.Time { return turnedAway.Add(time.Second) }},
+		{domain: "date.test", wantPause: dateFor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			c := NewRDAPChecker(stub.client(t), RDAPConfig{
+				DefaultLimit: RDAPLimit{Every: time.Millisecond, Burst: 10},
+			}).(*rdapChecker)
+
+			var result Result
+			registration, err := c.rdapWithRetry(context.Background(), tt.domain, &result)
+			if err != nil || registration != nil {
+				t.Fatalf("rdapWithRetry() = %+v, %v, want nil, nil", registration, err)
+			}
+			if result.Attempts != 2 {
+				t.Errorf("attempts = %d, want 2", result.Attempts)
+			}
+
+			queries := stub.queried(tt.domain)
+			if len(queries) != 2 {
+				t.Fatalf("server was queried %d times, want 2", len(queries))
+			}
+
+			// the pause is taken from the header, give or take how long the response took to arrive
+			want := tt.wantPause(queries[0])
+			paused := c.server(result.Server).pausedUntil
+			if d := paused.Sub(want); d < -50*time.Millisecond || d > 250*time.Millisecond {
+				t.Errorf("server paused until %v, want %v", paused, want)
+			}
+
+			// and nothing is sent before it's over, nor held back much longer
+			if wait := queries[1].Sub(paused); wait < 0 || wait > 250*time.Millisecond {
+				t.Errorf("retried %v after the pause ended, want right after", wait)
+			}
+		})
+	}
+}
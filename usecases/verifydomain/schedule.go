@@ -0,0 +1,97 @@
+package verifydomain
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+/** Pacer is implemented by checkers which rate limit themselves per key, eg per rdap server. Batches use it to hand
+ * out domains which can be checked right away before ones which would have a worker waiting on a limiter.
+ */
+type Pacer interface {
+	// PaceKey groups domains which share a budget, it's asked once per domain of a batch so it should be cheap
+	PaceKey(ctx context.Context, domainName string) string
+	// PaceDelay is how long until the budget of key can take another domain
+	PaceDelay(key string, now time.Time) time.Duration
+}
+
+type (
+	// schedule hands out indexes of a batch's domains, taking them round robin from groups sharing a budget and
+	// preferring groups with capacity
+	schedule struct {
+		pacers []Pacer
+		groups []*group
+		next   int // group to look at first, so no group is starved
+		n      int
+	}
+
+	group struct {
+		keys    []string // one per pacer
+		pending []int
+	}
+)
+
+func newSchedule(ctx context.Context, pacers []Pacer, domainNames []string) *schedule {
+	s := &schedule{pacers: pacers, n: len(domainNames)}
+
+	byKey := make(map[string]*group)
+	for i, d := range domainNames {
+		keys := make([]string, len(pacers))
+		for j, p := range pacers {
+			keys[j] = p.PaceKey(ctx, d)
+		}
+
+		k := strings.Join(keys, "\x00")
+		g, ok := byKey[k]
+		if !ok {
+			g = &group{keys: keys}
+			byKey[k] = g
+			s.groups = append(s.groups, g)
+		}
+		g.pending = append(g.pending, i)
+	}
+	return s
+}
+
+func (s *schedule) Len() int {
+	return s.n
+}
+
+// delay is how long until every budget the group draws from has capacity
+func (s *schedule) delay(g *group, now time.Time) time.Duration {
+	var d time.Duration
+	for j, p := range s.pacers {
+		d = max(d, p.PaceDelay(g.keys[j], now))
+	}
+	return d
+}
+
+/** Next returns the index of the next domain to check, from the first group with capacity or otherwise the group which
+ * gets capacity soonest, so workers are never left idle. Must only be called while Len is positive.
+ */
+func (s *schedule) Next(now time.Time) int {
+	best, bestDelay := -1, time.Duration(0)
+	for offset := range s.groups {
+		i := (s.next + offset) % len(s.groups)
+		g := s.groups[i]
+		if len(g.pending) == 0 {
+			continue
+		}
+
+		d := s.delay(g, now)
+		if best == -1 || d < bestDelay {
+			best, bestDelay = i, d
+		}
+		if d == 0 {
+			break
+		}
+	}
+
+	g := s.groups[best]
+	index := g.pending[0]
+	g.pending = g.pending[1:]
+	s.next = best + 1
+	s.n--
+	return index
+}
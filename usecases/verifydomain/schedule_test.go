@@ -0,0 +1,122 @@
+package verifydomain
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePacer gives every tld its own budget, which is out of capacity for as long as delays says
+type fakePacer struct {
+	delays map[string]time.Duration
+}
+
+func (p fakePacer) PaceKey(_ context.Context, domainName string) string {
+	return domainName[strings.LastIndex(domainName, ".")+1:]
+}
+
+func (p fakePacer) PaceDelay(key string, _ time.Time) time.Duration {
+	return p.delays[key]
+}
+
+// drain takes every index off of s in the order it hands them out
+func drain(s *schedule, now time.Time) []int {
+	var order []int
+	for s.Len() > 0 {
+		order = append(order, s.Next(now))
+	}
+	return order
+}
+
+func TestScheduleNext(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		delays  map[string]time.Duration
+		domains []string
+		want    []int
+	}{
+		{
+			name:    "without pacing groups take turns in the order they were first seen",
+			domains: []string{"a.x", "b.x", "c.x", "a.y", "b.y", "a.z"},
+			want:    []int{0, 3, 5, 1, 4, 2},
+		},
+		{
+			name:    "groups with capacity go before throttled ones",
+			delays:  map[string]time.Duration{"slow": time.Second},
+			domains: []string{"a.slow", "b.slow", "a.fast", "b.fast"},
+			want:    []int{2, 3, 0, 1},
+		},
+		{
+			name:    "when every group is throttled the one with capacity soonest goes first",
+			delays:  map[string]time.Duration{"x": 3 * time.Second, "y": time.Second, "z": 2 * time.Second},
+			domains: []string{"a.x", "a.y", "a.z"},
+			want:    []int{1, 2, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSchedule(context.Background(), []Pacer{fakePacer{delays: tt.delays}}, tt.domains)
+			if s.Len() != len(tt.domains) {
+				t.Fatalf("Len() = %d, want %d", s.Len(), len(tt.domains))
+			}
+			if got := drain(s, now); !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleDoesNotStarveGroups(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pacer := fakePacer{delays: map[string]time.Duration{}}
+	domains := []string{"a.x", "b.x", "c.x", "a.y", "b.y", "c.y", "a.z"}
+	s := newSchedule(context.Background(), []Pacer{pacer}, domains)
+
+	// x and y both have capacity throughout so they alternate rather than one draining first
+	pacer.delays["z"] = time.Minute
+	var got []int
+	for range 4 {
+		got = append(got, s.Next(now))
+	}
+	if want := []int{0, 3, 1, 4}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	// z gets its turn as soon as it has capacity again, ahead of the group which would have been next
+	pacer.delays["z"] = 0
+	if got := s.Next(now); got != 6 {
+		t.Errorf("Next() = %d, want z's domain 6", got)
+	}
+	if got := drain(s, now); !slices.Equal(got, []int{2, 5}) {
+		t.Errorf("order = %v, want [2 5]", got)
+	}
+}
+
+func TestScheduleWaitsOnEveryPacer(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// the second pacer groups by the first letter, so a.y shares a budget with a.x there
+	byTLD := fakePacer{delays: map[string]time.Duration{"x": time.Second}}
+	byLetter := letterPacer{delays: map[string]time.Duration{"a": time.Minute}}
+
+	s := newSchedule(context.Background(), []Pacer{byTLD, byLetter}, []string{"a.x", "a.y", "b.y"})
+	if got, want := drain(s, now), []int{2, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+// letterPacer gives every first letter its own budget
+type letterPacer struct {
+	delays map[string]time.Duration
+}
+
+func (p letterPacer) PaceKey(_ context.Context, domainName string) string {
+	return domainName[:1]
+}
+
+func (p letterPacer) PaceDelay(key string, _ time.Time) time.Duration {
+	return p.delays[key]
+}
@@ -5,16 +5,15 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
-
 	"github.com/khinshankhan/jitter-go/v2"
-	"github.com/khinshankhan/nomex/adapters/dnsresolver"
 	"github.com/khinshankhan/nomex/adapters/rdapclient"
 	"github.com/khinshankhan/nomex/data/domainban"
 	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/data/registration"
 	"github.com/khinshankhan/nomex/services/logx"
 	"github.com/khinshankhan/nomex/services/logx/fields"
 )
@@ -45,139 +44,213 @@ type (
 
 	// usecases declares the dependencies for the service
 	usecases struct {
-		domaincheckRepo domaincheck.Repository
-		domainbanRepo   domainban.Repository
+		domaincheckRepo  domaincheck.Repository
+		domainbanRepo    domainban.Repository
+		registrationRepo registration.Repository
+
+		checkers []Checker
+		pacers   []Pacer // checkers which pace themselves, used to order batches
 
-		dnsResolver *dnsresolver.Resolver
-		rdapClient  *rdapclient.Client
+		// asked for the registration of domains the chain found registered without it, nil to go without
+		details Checker
 
-		rdapMaxAttempts int
-		rdapLimiter     *rate.Limiter
+		// transient bans start at the base cooldown and double for repeat offenders
+		banBaseCooldown time.Duration
+		banMaxCooldown  time.Duration
 	}
 )
 
-// New returns Usecases
+/** New returns Usecases which verify domains by running them through checkers in order, eg zone file -> dns -> rdap.
+ * Checkers earlier in the chain usually settle registered domains without asking rdap, so registration details are
+ * only recorded for them if details is set, it's asked for the registration of every domain the chain found
+ * registered without one. It should be the same rdap checker as in the chain if there is one so they share a budget.
+ */
 func New(
 	domaincheckRepo domaincheck.Repository,
 	domainbanRepo domainban.Repository,
+	registrationRepo registration.Repository,
 
-	dnsResolver *dnsresolver.Resolver,
-	rdapClient *rdapclient.Client,
+	details Checker,
+	checkers ...Checker,
 ) Usecases {
+	var pacers []Pacer
+	for _, checker := range checkers {
+		if pacer, ok := checker.(Pacer); ok {
+			pacers = append(pacers, pacer)
+		}
+	}
+	if pacer, ok := details.(Pacer); ok && !slices.Contains(pacers, pacer) {
+		pacers = append(pacers, pacer)
+	}
+
 	return &usecases{
-		domaincheckRepo: domaincheckRepo,
-		domainbanRepo:   domainbanRepo,
+		domaincheckRepo:  domaincheckRepo,
+		domainbanRepo:    domainbanRepo,
+		registrationRepo: registrationRepo,
 
-		dnsResolver: dnsResolver,
-		rdapClient:  rdapClient,
+		checkers: checkers,
+		pacers:   pacers,
+		details:  details,
 
-		rdapMaxAttempts: 5,
-		// global RDAP rate limiter: 5 request every 15 seconds
-		rdapLimiter: rate.NewLimiter(rate.Every(15*time.Second), 5),
+		banBaseCooldown: time.Hour,
+		banMaxCooldown:  7 * 24 * time.Hour,
 	}
 }
 
-func shouldRetryRDAP(code int, err error) bool {
-	// 429 are rate limited and 502/503/504 are upstream/server/transient conditions.
-	if code == 429 || code == 502 || code == 503 || code == 504 {
-		return true
-	}
+// provenance tracks how a check reached its result
+type provenance struct {
+	source       domaincheck.Source
+	attempts     int
+	rdapServer   string
+	registration *rdapclient.Registration
+}
 
-	// respect caller context: if the context is done, don't keep retrying locally.
-	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-		return false
-	}
+// checkDomain runs the domain through the checker chain until one of them is definitive, otherwise the last tentative
+// verdict (or unsupported error) stands
+func (u *usecases) checkDomain(
+	ctx context.Context,
+	domainName string,
+	prov *provenance,
+) (domaincheck.Availability, error) {
+	availability := domaincheck.AvailabilityUnknown
+	var unsupportedErr error
+
+	for _, checker := range u.checkers {
+		result, err := checker.Check(ctx, domainName)
+		prov.source = checker.Source()
+		prov.attempts = result.Attempts
+		if result.Server != "" && prov.source == domaincheck.SourceRDAP {
+			prov.rdapServer = result.Server
+		}
+		if result.Registration != nil {
+			prov.registration = result.Registration
+		}
 
-	// net.Error covers common transient network failures.
-	// transport layer hiccups (dns lookup timeout, tcp reset, etc) are generally retryable.
-	// NOTE: temporary errors are not well-defined so they've been deprecated, but we lose nothing by checking it here.
-	var ne net.Error
-	if errors.As(err, &ne) && (ne.Timeout() || ne.Temporary()) {
-		return true
+		switch {
+		case err != nil && classifyError(err) == domaincheck.ErrorClassUnsupported:
+			// nothing went wrong, this checker just has nothing to ask
+			availability, unsupportedErr = domaincheck.AvailabilityUnknown, err
+		case err != nil:
+			return domaincheck.AvailabilityError, err
+		case result.Confidence == ConfidenceDefinitive:
+			return result.Verdict.availability(), nil
+		default:
+			availability, unsupportedErr = result.Verdict.availability(), nil
+		}
 	}
 
-	// transport error without code = retryable
-	return err != nil && code == 0
+	return availability, unsupportedErr
 }
 
-func (u *usecases) rdapWithRetry(backoffStrategy jitter.Strategy, ctx context.Context, domain string) (int, error) {
-	logger := logx.GetDefaultLogger()
+/** lookUpDetails asks the details checker for the registration of a domain the chain found registered without one.
+ * It's best effort, the chain's verdict stands whatever the details checker says.
+ */
+func (u *usecases) lookUpDetails(ctx context.Context, domainName string, availability domaincheck.Availability, prov *provenance) {
+	if u.details == nil || availability != domaincheck.AvailabilityRegistered || prov.registration != nil || ctx.Err() != nil {
+		return
+	}
 
-	var lastCode int
-	var lastErr error
+	logger := logx.GetDefaultLogger()
 
-	for attempt := 0; attempt < u.rdapMaxAttempts; attempt++ {
-		// reserve token and check the delay against ctx deadline
-		r := u.rdapLimiter.Reserve()
-		if !r.OK() {
-			return 429, errors.New("limiter burst too small")
-		}
-		delay := r.DelayFrom(time.Now())
-		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
-			r.Cancel()
-			return 408, context.DeadlineExceeded
-		}
+	result, err := u.details.Check(ctx, domainName)
+	switch {
+	case err != nil:
+		logger.Warn("failed to look up registration details",
+			fields.String("domain", domainName),
+			fields.Error(err),
+		)
+	case result.Registration == nil:
+		logger.Info("no registration details",
+			fields.String("domain", domainName),
+			fields.String("verdict", string(result.Verdict)),
+		)
+	default:
+		prov.registration = result.Registration
+		prov.rdapServer = result.Server
+	}
+}
 
-		// wait for token or ctx cancel
-		tokenT := time.NewTimer(delay)
-		select {
-		case <-tokenT.C:
-		case <-ctx.Done():
-			tokenT.Stop()
-			r.Cancel()
-			return 408, ctx.Err()
-		}
-		// r capacity is consumed here because we proceeded.
+// recordRegistration keeps the stored registration in line with what rdap said about the domain
+func (u *usecases) recordRegistration(domainName string, availability domaincheck.Availability, prov provenance, t time.Time) {
+	logger := logx.GetDefaultLogger()
 
-		code, err := u.rdapClient.Check(ctx, domain)
-		lastCode, lastErr = code, err
-		if !shouldRetryRDAP(code, err) {
-			return code, err
-		}
+	var err error
+	switch {
+	case availability == domaincheck.AvailabilityRegistered && prov.registration != nil:
+		reg := prov.registration
+		err = u.registrationRepo.SaveRegistration(registration.Registration{
+			Domain:          domainName,
+			Statuses:        reg.Statuses,
+			RegisteredAt:    reg.RegisteredAt,
+			ExpiresAt:       reg.ExpiresAt,
+			LastChangedAt:   reg.LastChangedAt,
+			Registrar:       nonEmpty(reg.Registrar),
+			RegistrarIANAID: nonEmpty(reg.RegistrarIANAID),
+			Nameservers:     reg.Nameservers,
+			CheckedAt:       t,
+		})
+	case availability == domaincheck.AvailabilityAvailable && prov.source == domaincheck.SourceRDAP:
+		// the registration is gone, whatever was stored is stale
+		err = u.registrationRepo.DeleteRegistration(domainName)
+	default:
+		return
+	}
 
-		logger.Warn("rdap check failed, will retry",
-			fields.String("domain", domain),
-			fields.Int("attempt", attempt+1),
-			fields.Int("code", code),
+	if err != nil {
+		logger.Warn("failed to record registration",
+			fields.String("domain", domainName),
 			fields.Error(err),
 		)
-
-		// use jittered delay exponentially scaled by number of failed attempts.
-		// attempt 0 should still wait a tiny bit to avoid stampedes.
-		sleepMs := backoffStrategy.Next(attempt)
-		sleep := time.Duration(sleepMs) * time.Millisecond
-		sleepT := time.NewTimer(sleep)
-		select {
-		case <-sleepT.C:
-		case <-ctx.Done():
-			sleepT.Stop()
-			return lastCode, ctx.Err()
-		}
 	}
+}
 
-	logger.Warn("rdap retries exhausted",
-		fields.String("domain", domain),
-		fields.Int("attempts", u.rdapMaxAttempts),
-		fields.Int("last_code", lastCode),
-		fields.Error(lastErr),
-	)
-	return lastCode, lastErr
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
 
-func (u *usecases) checkDomain(backoffStrategy jitter.Strategy, ctx context.Context, domainName string) (int, error) {
-	taken, err := u.dnsResolver.Check(ctx, domainName)
+// strikeDomain bans a domain until its cooldown expires, after which it's picked up as pending again
+func (u *usecases) strikeDomain(domainName string, reason string, t time.Time) {
+	logger := logx.GetDefaultLogger()
+
+	ban, err := u.domainbanRepo.StrikeDomain(domainName, reason, t, u.banBaseCooldown, u.banMaxCooldown)
 	if err != nil {
-		return 500, err
+		logger.Warn("failed to ban domain",
+			fields.String("domain", domainName),
+			fields.String("reason", reason),
+			fields.Error(err),
+		)
+		return
 	}
-
-	// we can trust dns if it says domain is taken
-	if taken {
-		return 200, nil
+	if ban.ExpiresAt != nil {
+		logger.Info("banned domain until cooldown expires",
+			fields.String("domain", domainName),
+			fields.String("reason", reason),
+			fields.Int("strikes", ban.Strikes),
+			fields.TimeField("expires_at", *ban.ExpiresAt),
+		)
 	}
+}
+
+// deferDomain holds a domain back until the given time without striking it, see domainban.DeferDomain
+func (u *usecases) deferDomain(domainName string, reason string, t time.Time, until time.Time) {
+	logger := logx.GetDefaultLogger()
 
-	// domain is not found in dns, double-check with rdap (with retries)
-	code, err := u.rdapWithRetry(backoffStrategy, ctx, domainName)
-	return code, err
+	if err := u.domainbanRepo.DeferDomain(domainName, reason, t, until); err != nil {
+		logger.Warn("failed to defer domain",
+			fields.String("domain", domainName),
+			fields.String("reason", reason),
+			fields.Error(err),
+		)
+		return
+	}
+	logger.Info("deferred domain",
+		fields.String("domain", domainName),
+		fields.String("reason", reason),
+		fields.TimeField("until", until),
+	)
 }
 
 type VerificationResult struct {
@@ -193,23 +266,53 @@ func (u *usecases) VerifyRaw(backoffStrategy jitter.Strategy, ctx context.Contex
 	// TODO: check code to avoid getting ratelimited/ other issues
 
 	// short, per-domain timeout layered on caller ctx
+	parentCtx := ctx
 	ctx, cancel := context.WithTimeout(
-		ctx,
+		parentCtx,
 		// NOTE: this should be greater than the max attempts * rps allowed by the rdap limiter * average rdap response
 		// time and account for exponential backoff + n routines running in parallel
 		75*time.Second,
 	)
 	defer cancel()
 
-	code, err := u.checkDomain(backoffStrategy, ctx, domainName)
+	var prov provenance
+	availability, err := u.checkDomain(withBackoff(ctx, backoffStrategy), domainName, &prov)
+	latency := time.Since(t)
+	u.lookUpDetails(withBackoff(ctx, backoffStrategy), domainName, availability, &prov)
 	checkedDomain := domaincheck.DomainCheck{
-		Domain: domainName,
-		Code:   &code,
-		At:     &t,
+		Domain:       domainName,
+		Availability: &availability,
+		At:           &t,
+		Source:       &prov.source,
+		Attempts:     &prov.attempts,
+		Latency:      &latency,
+	}
+	if prov.rdapServer != "" {
+		checkedDomain.RDAPServer = &prov.rdapServer
+	}
+	if err != nil {
+		errorClass := classifyError(err)
+		errorMessage := err.Error()
+		checkedDomain.ErrorClass = &errorClass
+		checkedDomain.Error = &errorMessage
+	}
+
+	// the caller gave up (eg shutting down) so whatever we got is an artifact of the cancellation rather than a real
+	// result, leave the domain untouched so it stays pending for the next run
+	if parentErr := parentCtx.Err(); parentErr != nil {
+		logger.Info("abandoned domain check",
+			fields.String("domain", domainName),
+			fields.Error(parentErr),
+		)
+		return VerificationResult{
+			CheckedDomain: checkedDomain,
+			Err:           parentErr,
+		}
 	}
 
 	if err != nil {
 		var dnsErr *net.DNSError
+		var circuitErr *CircuitOpenError
 		switch {
 		// TODO: circle back to this, this may be insufficient...
 		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
@@ -217,25 +320,16 @@ func (u *usecases) VerifyRaw(backoffStrategy jitter.Strategy, ctx context.Contex
 			// domain check should've verified via RDAP as well so we can move on
 			break
 		case errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout):
-			// transient resolver issue -> "ban" (or defer) and move on
-			reason := "temporary DNS failure"
-			_ = u.domainbanRepo.BanDomain(
-				domainban.DomainBan{
-					Domain: domainName,
-					Reason: &reason,
-					At:     &t,
-				},
-			)
+			// transient resolver issue -> defer and move on
+			u.strikeDomain(domainName, "temporary DNS failure", t)
+			break
+		case errors.As(err, &circuitErr):
+			// the rdap server is down, which is no fault of the domain's, so hold it back until the server may be tried
+			// again rather than striking it
+			u.deferDomain(domainName, "rdap server unavailable", t, circuitErr.Until)
 			break
 		case errors.Is(err, context.DeadlineExceeded):
-			reason := "timeout"
-			_ = u.domainbanRepo.BanDomain(
-				domainban.DomainBan{
-					Domain: domainName,
-					Reason: &reason,
-					At:     &t,
-				},
-			)
+			u.strikeDomain(domainName, "timeout", t)
 			break
 		default:
 			logger.Warn("checkDomain unexpected error",
@@ -261,19 +355,34 @@ func (u *usecases) VerifyRaw(backoffStrategy jitter.Strategy, ctx context.Contex
 		}
 	}
 
+	u.recordRegistration(domainName, availability, prov, t)
+
+	// a definitive answer means whatever was failing before has cleared up
+	if availability.Definitive() {
+		if err := u.domainbanRepo.ClearTransientBan(domainName); err != nil {
+			logger.Warn("failed to clear transient ban",
+				fields.String("domain", domainName),
+				fields.Error(err),
+			)
+		}
+	}
+
 	return VerificationResult{
 		CheckedDomain: checkedDomain,
 		Err:           nil,
 	}
 }
 
-func (u *usecases) Verify(ctx context.Context, domainName string) VerificationResult {
+// newRandomBackoff returns a backoff strategy seeded from the clock
+func newRandomBackoff() jitter.Strategy {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	backoffStrategy := newBackoff(
+	return newBackoff(
 		r.Int63n,
 	)
+}
 
-	return u.VerifyRaw(backoffStrategy, ctx, domainName)
+func (u *usecases) Verify(ctx context.Context, domainName string) VerificationResult {
+	return u.VerifyRaw(newRandomBackoff(), ctx, domainName)
 }
 
 func (u *usecases) VerifyBatchRaw(
@@ -292,6 +401,17 @@ func (u *usecases) VerifyBatchRaw(
 
 	total := len(domainNames)
 	results := make([]VerificationResult, total)
+	// anything never dispatched is reported as abandoned
+	for i, d := range domainNames {
+		results[i] = VerificationResult{
+			CheckedDomain: domaincheck.DomainCheck{Domain: d},
+			Err:           context.Canceled,
+		}
+	}
+
+	// workers ask for a job when they're free so the pick reflects which budgets have capacity at that moment
+	want := make(chan struct{})
+	done := make(chan struct{})
 
 	var wg sync.WaitGroup
 	worker := func(workerId int) {
@@ -301,7 +421,17 @@ func (u *usecases) VerifyBatchRaw(
 			// seed with workerId with a bit of magnitude to ensure different sequences
 			workerId * 10_000,
 		)
-		for j := range jobs {
+		for {
+			select {
+			case want <- struct{}{}:
+			case <-done:
+				return
+			}
+			j, ok := <-jobs
+			if !ok {
+				return
+			}
+
 			logger.Info("Verifying",
 				fields.Int("i", j.i+1),
 				fields.Int("n", total),
@@ -311,9 +441,16 @@ func (u *usecases) VerifyBatchRaw(
 			result := u.VerifyRaw(backoffStrategy, ctx, j.d)
 			results[j.i] = result
 
+			if ctx.Err() != nil {
+				logger.Info("Abandoned",
+					fields.String("name", j.d),
+				)
+				continue
+			}
+
 			logger.Info("Verified",
 				fields.String("name", j.d),
-				fields.Int("code", *result.CheckedDomain.Code),
+				fields.String("availability", string(*result.CheckedDomain.Availability)),
 			)
 
 		}
@@ -323,11 +460,32 @@ func (u *usecases) VerifyBatchRaw(
 		go worker(w)
 	}
 
-	for i, d := range domainNames {
-		jobs <- job{i, d}
+	// stop handing out work as soon as the caller cancels, in-flight domains are left to the workers to wrap up
+	sched := newSchedule(ctx, u.pacers, domainNames)
+	dispatched := 0
+dispatch:
+	for sched.Len() > 0 {
+		select {
+		case <-want:
+			// the worker which asked is waiting on jobs
+			i := sched.Next(time.Now())
+			jobs <- job{i, domainNames[i]}
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
 	}
+	close(done)
 	close(jobs)
 	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		logger.Warn("Batch cancelled, remaining domains stay pending",
+			fields.Int("dispatched", dispatched),
+			fields.Int("n", total),
+			fields.Error(err),
+		)
+	}
 	return results
 }
 
@@ -0,0 +1,223 @@
+package verifydomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/khinshankhan/nomex/adapters/rdapclient"
+	"github.com/khinshankhan/nomex/adapters/whoisclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+// fakeChecker answers every domain the same way and records that it was asked
+type fakeChecker struct {
+	source domaincheck.Source
+	result Result
+	err    error
+	asked  *[]domaincheck.Source
+}
+
+func (c fakeChecker) Source() domaincheck.Source {
+	return c.source
+}
+
+func (c fakeChecker) Check(ctx context.Context, domainName string) (Result, error) {
+	*c.asked = append(*c.asked, c.source)
+	return c.result, c.err
+}
+
+func TestCheckDomain(t *testing.T) {
+	definitive := func(v Verdict) Result { return Result{Verdict: v, Confidence: ConfidenceDefinitive, Attempts: 1} }
+	tentative := func(v Verdict) Result { return Result{Verdict: v, Confidence: ConfidenceTentative, Attempts: 1} }
+	unsupported := fmt.Errorf("%w: test", whoisclient.WhoisNoServerError)
+	failed := errors.New("connection reset")
+
+	type stage struct {
+		source domaincheck.Source
+		result Result
+		err    error
+	}
+	tests := []struct {
+		name       string
+		stages     []stage
+		want       domaincheck.Availability
+		wantErr    error
+		wantAsked  []domaincheck.Source
+		wantSource domaincheck.Source
+	}{
+		{
+			name: "definitive answer stops the chain",
+			stages: []stage{
+				{source: domaincheck.SourceZoneFile, result: definitive(VerdictTaken)},
+				{source: domaincheck.SourceRDAP, result: definitive(VerdictAvailable)},
+			},
+			want:       domaincheck.AvailabilityRegistered,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceZoneFile},
+			wantSource: domaincheck.SourceZoneFile,
+		},
+		{
+			name: "unsupported moves on to the next checker",
+			stages: []stage{
+				{source: domaincheck.SourceWHOIS, err: unsupported},
+				{source: domaincheck.SourceRDAP, result: definitive(VerdictAvailable)},
+			},
+			want:       domaincheck.AvailabilityAvailable,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceWHOIS, domaincheck.SourceRDAP},
+			wantSource: domaincheck.SourceRDAP,
+		},
+		{
+			name: "unsupported by the last checker is reported",
+			stages: []stage{
+				{source: domaincheck.SourceRDAP, result: tentative(VerdictUnknown)},
+				{source: domaincheck.SourceWHOIS, err: unsupported},
+			},
+			want:       domaincheck.AvailabilityUnknown,
+			wantErr:    unsupported,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceRDAP, domaincheck.SourceWHOIS},
+			wantSource: domaincheck.SourceWHOIS,
+		},
+		{
+			name: "any other error stops the chain",
+			stages: []stage{
+				{source: domaincheck.SourceDNS, result: tentative(VerdictAvailable)},
+				{source: domaincheck.SourceRDAP, err: failed},
+				{source: domaincheck.SourceWHOIS, result: definitive(VerdictAvailable)},
+			},
+			want:       domaincheck.AvailabilityError,
+			wantErr:    failed,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceDNS, domaincheck.SourceRDAP},
+			wantSource: domaincheck.SourceRDAP,
+		},
+		{
+			name: "tentative available stands if it's the last word",
+			stages: []stage{
+				{source: domaincheck.SourceZoneFile, result: tentative(VerdictUnknown)},
+				{source: domaincheck.SourceDNS, result: tentative(VerdictAvailable)},
+			},
+			want:       domaincheck.AvailabilityAvailable,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceZoneFile, domaincheck.SourceDNS},
+			wantSource: domaincheck.SourceDNS,
+		},
+		{
+			name: "tentative available is overruled by a later definitive answer",
+			stages: []stage{
+				{source: domaincheck.SourceDNS, result: tentative(VerdictAvailable)},
+				{source: domaincheck.SourceRDAP, result: definitive(VerdictTaken)},
+			},
+			want:       domaincheck.AvailabilityRegistered,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceDNS, domaincheck.SourceRDAP},
+			wantSource: domaincheck.SourceRDAP,
+		},
+		{
+			name: "tentative available is dropped when a later checker is unsupported",
+			stages: []stage{
+				{source: domaincheck.SourceDNS, result: tentative(VerdictAvailable)},
+				{source: domaincheck.SourceRDAP, err: unsupported},
+			},
+			want:       domaincheck.AvailabilityUnknown,
+			wantErr:    unsupported,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceDNS, domaincheck.SourceRDAP},
+			wantSource: domaincheck.SourceRDAP,
+		},
+		{
+			name: "tentative available is dropped when a later checker can't tell",
+			stages: []stage{
+				{source: domaincheck.SourceDNS, result: tentative(VerdictAvailable)},
+				{source: domaincheck.SourceRDAP, result: tentative(VerdictUnknown)},
+			},
+			want:       domaincheck.AvailabilityUnknown,
+			wantAsked:  []domaincheck.Source{domaincheck.SourceDNS, domaincheck.SourceRDAP},
+			wantSource: domaincheck.SourceRDAP,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []domaincheck.Source
+			checkers := make([]Checker, 0, len(tt.stages))
+			for _, s := range tt.stages {
+				checkers = append(checkers, fakeChecker{source: s.source, result: s.result, err: s.err, asked: &asked})
+			}
+			u := &usecases{checkers: checkers}
+
+			var prov provenance
+			got, err := u.checkDomain(context.Background(), "example.test", &prov)
+			if got != tt.want {
+				t.Errorf("checkDomain() availability = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("checkDomain() error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(asked, tt.wantAsked) {
+				t.Errorf("checkers asked = %v, want %v", asked, tt.wantAsked)
+			}
+			if prov.source != tt.wantSource {
+				t.Errorf("provenance source = %q, want %q", prov.source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLookUpDetails(t *testing.T) {
+	reg := &rdapclient.Registration{Domain: "example.test", Statuses: []string{"clientHold"}}
+	details := Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Registration: reg, Server: "https://rdap.example/"}
+
+	tests := []struct {
+		name         string
+		availability domaincheck.Availability
+		prov         provenance
+		result       Result
+		err          error
+		wantAsked    bool
+		wantReg      *rdapclient.Registration
+	}{
+		{
+			name:         "registered without a registration asks rdap",
+			availability: domaincheck.AvailabilityRegistered,
+			prov:         provenance{source: domaincheck.SourceDNS},
+			result:       details,
+			wantAsked:    true,
+			wantReg:      reg,
+		},
+		{
+			name:         "registered with a registration already",
+			availability: domaincheck.AvailabilityRegistered,
+			prov:         provenance{source: domaincheck.SourceRDAP, registration: &rdapclient.Registration{Domain: "example.test"}},
+			result:       details,
+			wantReg:      &rdapclient.Registration{Domain: "example.test"},
+		},
+		{
+			name:         "available isn't looked up",
+			availability: domaincheck.AvailabilityAvailable,
+			prov:         provenance{source: domaincheck.SourceDNS},
+			result:       details,
+		},
+		{
+			name:         "a failed lookup leaves the check alone",
+			availability: domaincheck.AvailabilityRegistered,
+			prov:         provenance{source: domaincheck.SourceZoneFile},
+			err:          errors.New("connection reset"),
+			wantAsked:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []domaincheck.Source
+			u := &usecases{details: fakeChecker{source: domaincheck.SourceRDAP, result: tt.result, err: tt.err, asked: &asked}}
+
+			prov := tt.prov
+			u.lookUpDetails(context.Background(), "example.test", tt.availability, &prov)
+			if (len(asked) > 0) != tt.wantAsked {
+				t.Errorf("details asked = %v, want %v", len(asked) > 0, tt.wantAsked)
+			}
+			if (prov.registration == nil) != (tt.wantReg == nil) || (prov.registration != nil && prov.registration.Domain != tt.wantReg.Domain) {
+				t.Errorf("registration = %+v, want %+v", prov.registration, tt.wantReg)
+			}
+			if prov.source != tt.prov.source {
+				t.Errorf("source = %q, want %q unchanged", prov.source, tt.prov.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package verifydomain
+
+import (
+	"context"
+
+	"github.com/khinshankhan/nomex/adapters/whoisclient"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+)
+
+type whoisChecker struct {
+	whoisClient *whoisclient.Client
+}
+
+// NewWHOISChecker checks domains over WHOIS, meant to follow rdap in the chain for tlds which don't support it
+func NewWHOISChecker(whoisClient *whoisclient.Client) Checker {
+	return whoisChecker{whoisClient: whoisClient}
+}
+
+func (c whoisChecker) Source() domaincheck.Source {
+	return domaincheck.SourceWHOIS
+}
+
+func (c whoisChecker) Check(ctx context.Context, domainName string) (Result, error) {
+	taken, server, err := c.whoisClient.Check(ctx, domainName)
+	result := Result{Verdict: VerdictUnknown, Attempts: 1, Server: server}
+	if err != nil {
+		return result, err
+	}
+
+	result.Verdict = VerdictAvailable
+	if taken {
+		result.Verdict = VerdictTaken
+	}
+	result.Confidence = ConfidenceDefinitive
+	return result, nil
+}
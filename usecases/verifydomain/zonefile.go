@@ -0,0 +1,44 @@
+package verifydomain
+
+import (
+	"context"
+
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/data/zonedomain"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+)
+
+type zoneFileChecker struct {
+	zonedomainRepo zonedomain.Repository
+}
+
+/** NewZoneFileChecker checks domains against the loaded zone files. Zone files are a snapshot of delegations so being
+ * in one is proof enough without touching the network, but not being in one proves nothing (the snapshot may be stale
+ * or the domain may not be delegated) so those are passed on.
+ */
+func NewZoneFileChecker(zonedomainRepo zonedomain.Repository) Checker {
+	return zoneFileChecker{zonedomainRepo: zonedomainRepo}
+}
+
+func (c zoneFileChecker) Source() domaincheck.Source {
+	return domaincheck.SourceZoneFile
+}
+
+func (c zoneFileChecker) Check(_ context.Context, domainName string) (Result, error) {
+	logger := logx.GetDefaultLogger()
+
+	inZone, err := c.zonedomainRepo.Contains(domainName)
+	if err != nil {
+		// a broken pre-filter shouldn't fail the check, the rest of the chain can still answer
+		logger.Warn("zone lookup failed, passing on",
+			fields.String("domain", domainName),
+			fields.Error(err),
+		)
+		return Result{Verdict: VerdictUnknown, Confidence: ConfidenceTentative, Attempts: 1}, nil
+	}
+	if inZone {
+		return Result{Verdict: VerdictTaken, Confidence: ConfidenceDefinitive, Attempts: 1}, nil
+	}
+	return Result{Verdict: VerdictUnknown, Confidence: ConfidenceTentative, Attempts: 1}, nil
+}
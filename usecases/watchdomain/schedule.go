@@ -0,0 +1,69 @@
+package watchdomain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/watch"
+)
+
+const (
+	day = 24 * time.Hour
+
+	// gtld deletion timeline (ICANN's expired registration recovery policy), ccTLDs vary but this is a fair guess
+	autoRenewGracePeriod = 45 * day
+	redemptionPeriod     = 30 * day
+	pendingDeletePeriod  = 5 * day
+)
+
+/** estimateDrop works out why a domain is expected to drop and roughly when, from the latest registration. The status
+ * changed when the registration was last changed so that's when its period started, falling back to now if it's
+ * unknown. Returns an empty reason if the domain isn't on its way to being deleted.
+ */
+func estimateDrop(reg registration.Registration, now time.Time, expiringWithin time.Duration) (watch.Reason, *time.Time) {
+	since := now
+	if reg.LastChangedAt != nil && reg.LastChangedAt.Before(now) {
+		since = *reg.LastChangedAt
+	}
+
+	var reason watch.Reason
+	var drop time.Time
+	switch {
+	case slices.Contains(reg.Statuses, registration.StatusPendingDelete):
+		reason, drop = watch.ReasonPendingDelete, since.Add(pendingDeletePeriod)
+	case slices.Contains(reg.Statuses, registration.StatusRedemptionPeriod):
+		reason, drop = watch.ReasonRedemptionPeriod, since.Add(redemptionPeriod+pendingDeletePeriod)
+	case reg.ExpiresAt != nil && reg.ExpiresAt.Before(now.Add(expiringWithin)):
+		reason, drop = watch.ReasonExpiring, reg.ExpiresAt.Add(autoRenewGracePeriod+redemptionPeriod+pendingDeletePeriod)
+	default:
+		return "", nil
+	}
+	return reason, &drop
+}
+
+/** nextCheck schedules the next check of a domain, tightening as the estimated drop gets closer and loosening again
+ * the further past it the domain is, since the estimate was clearly off. Each interval is shorter than the distance
+ * to the estimate so a check never jumps over it.
+ */
+func nextCheck(now time.Time, estimatedDropAt *time.Time) time.Time {
+	if estimatedDropAt == nil {
+		return now.Add(day)
+	}
+
+	d := estimatedDropAt.Sub(now).Abs()
+	var interval time.Duration
+	switch {
+	case d > 7*day:
+		interval = day
+	case d > day:
+		interval = 6 * time.Hour
+	case d > 6*time.Hour:
+		interval = time.Hour
+	case d > time.Hour:
+		interval = 15 * time.Minute
+	default:
+		interval = time.Minute
+	}
+	return now.Add(interval)
+}
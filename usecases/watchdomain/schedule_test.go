@@ -0,0 +1,125 @@
+package watchdomain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/watch"
+)
+
+func TestEstimateDrop(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	const expiringWithin = 30 * day
+
+	tests := []struct {
+		name       string
+		reg        registration.Registration
+		wantReason watch.Reason
+		wantDrop   *time.Time
+	}{
+		{
+			name:       "pending delete drops five days after it started",
+			reg:        registration.Registration{Statuses: []string{registration.StatusPendingDelete}, LastChangedAt: at(-2 * day)},
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   at(3 * day),
+		},
+		{
+			name:       "pending delete wins over redemption",
+			reg:        registration.Registration{Statuses: []string{registration.StatusRedemptionPeriod, registration.StatusPendingDelete}, LastChangedAt: at(-day)},
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   at(4 * day),
+		},
+		{
+			name:       "redemption drops after redemption and pending delete",
+			reg:        registration.Registration{Statuses: []string{registration.StatusRedemptionPeriod}, LastChangedAt: at(-10 * day)},
+			wantReason: watch.ReasonRedemptionPeriod,
+			wantDrop:   at(25 * day),
+		},
+		{
+			name:       "unknown last change counts from now",
+			reg:        registration.Registration{Statuses: []string{registration.StatusRedemptionPeriod}},
+			wantReason: watch.ReasonRedemptionPeriod,
+			wantDrop:   at(35 * day),
+		},
+		{
+			name:       "last change in the future counts from now",
+			reg:        registration.Registration{Statuses: []string{registration.StatusPendingDelete}, LastChangedAt: at(day)},
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   at(5 * day),
+		},
+		{
+			name:       "expiring soon drops after grace, redemption and pending delete",
+			reg:        registration.Registration{Statuses: []string{"active"}, ExpiresAt: at(10 * day)},
+			wantReason: watch.ReasonExpiring,
+			wantDrop:   at(90 * day),
+		},
+		{
+			name:       "already expired",
+			reg:        registration.Registration{ExpiresAt: at(-50 * day)},
+			wantReason: watch.ReasonExpiring,
+			wantDrop:   at(30 * day),
+		},
+		{
+			name: "expiring later isn't watched",
+			reg:  registration.Registration{Statuses: []string{"active"}, ExpiresAt: at(expiringWithin + day)},
+		},
+		{
+			name: "unknown expiry isn't watched",
+			reg:  registration.Registration{Statuses: []string{"clientHold"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, drop := estimateDrop(tt.reg, now, expiringWithin)
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if (drop == nil) != (tt.wantDrop == nil) || (drop != nil && !drop.Equal(*tt.wantDrop)) {
+				t.Errorf("drop = %v, want %v", drop, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func TestNextCheck(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name         string
+		estimate     *time.Time
+		wantInterval time.Duration
+	}{
+		{name: "no estimate", estimate: nil, wantInterval: day},
+		{name: "weeks out", estimate: at(30 * day), wantInterval: day},
+		{name: "a week out", estimate: at(7 * day), wantInterval: 6 * time.Hour},
+		{name: "days out", estimate: at(3 * day), wantInterval: 6 * time.Hour},
+		{name: "a day out", estimate: at(day), wantInterval: time.Hour},
+		{name: "hours out", estimate: at(3 * time.Hour), wantInterval: 15 * time.Minute},
+		{name: "an hour out", estimate: at(time.Hour), wantInterval: time.Minute},
+		{name: "due now", estimate: at(0), wantInterval: time.Minute},
+		{name: "just past", estimate: at(-30 * time.Minute), wantInterval: time.Minute},
+		{name: "hours past", estimate: at(-3 * time.Hour), wantInterval: 15 * time.Minute},
+		{name: "weeks past", estimate: at(-30 * day), wantInterval: day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextCheck(now, tt.estimate)
+			if want := now.Add(tt.wantInterval); !got.Equal(want) {
+				t.Errorf("nextCheck() = %v, want %v", got.Sub(now), tt.wantInterval)
+			}
+			// a check never jumps over the estimate
+			if tt.estimate != nil && tt.estimate.After(now) && got.After(*tt.estimate) {
+				t.Errorf("nextCheck() = %v is past the estimate %v", got, *tt.estimate)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package watchdomain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/watch"
+	"github.com/khinshankhan/nomex/services/logx"
+	"github.com/khinshankhan/nomex/services/logx/fields"
+	"github.com/khinshankhan/nomex/usecases/verifydomain"
+)
+
+type (
+	// Usecases declares available services
+	Usecases interface {
+		Sync(now time.Time) (int, error)
+		CheckDue(maxParallel int, ctx context.Context, onEvent func(watch.Event)) error
+		Run(maxParallel int, syncEvery time.Duration, ctx context.Context, onEvent func(watch.Event)) error
+	}
+
+	// usecases declares the dependencies for the service
+	usecases struct {
+		watchRepo        watch.Repository
+		registrationRepo registration.Repository
+
+		verifydomainUsecase verifydomain.Usecases
+
+		// domains expiring within this long are watched even before they've gone into redemption
+		expiringWithin time.Duration
+	}
+)
+
+/** New returns Usecases which keep a watchlist of domains about to drop, found from the registrations rdap has
+ * reported, and recheck them with verifydomainUsecase on a schedule which tightens around their estimated drop.
+ * verifydomainUsecase has to record the registration of every registered domain, ie have rdap in its chain before
+ * anything which settles registered domains or be given rdap for details, see verifydomain.New.
+ */
+func New(
+	watchRepo watch.Repository,
+	registrationRepo registration.Repository,
+	verifydomainUsecase verifydomain.Usecases,
+	expiringWithin time.Duration,
+) Usecases {
+	return &usecases{
+		watchRepo:        watchRepo,
+		registrationRepo: registrationRepo,
+
+		verifydomainUsecase: verifydomainUsecase,
+
+		expiringWithin: expiringWithin,
+	}
+}
+
+// Sync adds every registration on its way to being deleted to the watchlist, returning how many were added or updated
+func (u *usecases) Sync(now time.Time) (int, error) {
+	regs, err := u.registrationRepo.GetDropCandidates(now.Add(u.expiringWithin))
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, reg := range regs {
+		reason, estimatedDropAt := estimateDrop(reg, now, u.expiringWithin)
+		if reason == "" {
+			continue
+		}
+
+		err := u.watchRepo.WatchDomain(watch.Entry{
+			Domain:          reg.Domain,
+			Reason:          reason,
+			EstimatedDropAt: estimatedDropAt,
+			NextCheckAt:     nextCheck(now, estimatedDropAt),
+			AddedAt:         now,
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// unwatch takes the domain off the watchlist and emits the event
+func (u *usecases) unwatch(event watch.Event, onEvent func(watch.Event)) error {
+	if err := u.watchRepo.UnwatchDomain(event); err != nil {
+		return err
+	}
+	onEvent(event)
+	return nil
+}
+
+// recheck updates the entry with the outcome of verifying the domain
+func (u *usecases) recheck(entry watch.Entry, result verifydomain.VerificationResult, now time.Time, onEvent func(watch.Event)) error {
+	logger := logx.GetDefaultLogger()
+
+	entry.LastCheckedAt = &now
+	entry.Checks++
+
+	availability := domaincheck.AvailabilityUnknown
+	if result.Err == nil && result.CheckedDomain.Availability != nil {
+		availability = *result.CheckedDomain.Availability
+	}
+
+	switch availability {
+	case domaincheck.AvailabilityAvailable:
+		return u.unwatch(watch.Event{Domain: entry.Domain, Kind: watch.EventDropped, At: now}, onEvent)
+	case domaincheck.AvailabilityRegistered:
+		// verifydomainUsecase must ask rdap for the registration even when an earlier checker settles the domain, if rdap
+		// couldn't be reached the last registration stands
+		reg, err := u.registrationRepo.GetRegistration(entry.Domain)
+		if err != nil {
+			return err
+		}
+		if reg != nil {
+			reason, estimatedDropAt := estimateDrop(*reg, now, u.expiringWithin)
+			if reason == "" {
+				return u.unwatch(watch.Event{Domain: entry.Domain, Kind: watch.EventRenewed, At: now}, onEvent)
+			}
+			entry.Reason, entry.EstimatedDropAt = reason, estimatedDropAt
+		}
+	default:
+		logger.Warn("watched domain check was inconclusive",
+			fields.String("domain", entry.Domain),
+			fields.String("availability", string(availability)),
+			fields.Error(result.Err),
+		)
+	}
+
+	entry.NextCheckAt = nextCheck(now, entry.EstimatedDropAt)
+	return u.watchRepo.SaveEntry(entry)
+}
+
+/** CheckDue rechecks every domain due for a check, calling onEvent as soon as one drops or stops being expected to.
+ * Up to maxParallel domains are checked at once so onEvent may be called concurrently.
+ */
+func (u *usecases) CheckDue(maxParallel int, ctx context.Context, onEvent func(watch.Event)) error {
+	logger := logx.GetDefaultLogger()
+
+	entries, err := u.watchRepo.GetDueEntries(time.Now())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// each domain is handled as soon as its check is done rather than once the whole round is, a drop can't wait
+	sem := make(chan struct{}, max(maxParallel, 1))
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// abandoned checks stay due for the next round
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := u.verifydomainUsecase.Verify(ctx, entry.Domain)
+			if ctx.Err() != nil {
+				return
+			}
+			if err := u.recheck(entry, result, time.Now(), onEvent); err != nil {
+				logger.Error("failed to update watched domain",
+					fields.String("domain", entry.Domain),
+					fields.Error(err),
+				)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+/** Run keeps checking the watchlist until ctx is done, syncing it with the registrations every syncEvery and sleeping
+ * until the next check is due in between.
+ */
+func (u *usecases) Run(maxParallel int, syncEvery time.Duration, ctx context.Context, onEvent func(watch.Event)) error {
+	logger := logx.GetDefaultLogger()
+
+	var nextSync time.Time
+	for {
+		if now := time.Now(); !now.Before(nextSync) {
+			n, err := u.Sync(now)
+			if err != nil {
+				return err
+			}
+			logger.Info("Synced watchlist", fields.Int("n", n))
+			nextSync = now.Add(syncEvery)
+		}
+
+		if err := u.CheckDue(maxParallel, ctx, onEvent); err != nil {
+			return err
+		}
+
+		wake := nextSync
+		next, err := u.watchRepo.GetNextCheckAt()
+		if err != nil {
+			return err
+		}
+		if next != nil && next.Before(wake) {
+			wake = *next
+		}
+
+		t := time.NewTimer(time.Until(wake))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
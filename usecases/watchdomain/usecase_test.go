@@ -0,0 +1,322 @@
+package watchdomain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khinshankhan/jitter-go/v2"
+	"github.com/khinshankhan/nomex/data/domaincheck"
+	"github.com/khinshankhan/nomex/data/registration"
+	"github.com/khinshankhan/nomex/data/watch"
+	"github.com/khinshankhan/nomex/infra/sqlite"
+	"github.com/khinshankhan/nomex/usecases/verifydomain"
+)
+
+/** fakeVerifier answers Verify with the availability it's given for each domain, an error for any other, and records
+ * which domains it was asked about. Registrations are saved along the way like rdap would.
+ */
+type fakeVerifier struct {
+	registrationRepo registration.Repository
+	availability     map[string]domaincheck.Availability
+	registrations    map[string]registration.Registration
+
+	mu    sync.Mutex
+	asked []string
+}
+
+func (v *fakeVerifier) Verify(_ context.Context, domainName string) verifydomain.VerificationResult {
+	v.mu.Lock()
+	v.asked = append(v.asked, domainName)
+	v.mu.Unlock()
+
+	availability, ok := v.availability[domainName]
+	if !ok {
+		return verifydomain.VerificationResult{
+			CheckedDomain: domaincheck.DomainCheck{Domain: domainName},
+			Err:           errors.New("connection reset"),
+		}
+	}
+	if reg, ok := v.registrations[domainName]; ok {
+		if err := v.registrationRepo.SaveRegistration(reg); err != nil {
+			panic(err)
+		}
+	}
+	return verifydomain.VerificationResult{
+		CheckedDomain: domaincheck.DomainCheck{Domain: domainName, Availability: &availability},
+	}
+}
+
+func (v *fakeVerifier) VerifyRaw(_ jitter.Strategy, ctx context.Context, domainName string) verifydomain.VerificationResult {
+	return v.Verify(ctx, domainName)
+}
+
+func (v *fakeVerifier) VerifyBatchRaw(_ func(int) jitter.Strategy, maxParallel int, ctx context.Context, domainNames []string) []verifydomain.VerificationResult {
+	return v.VerifyBatch(maxParallel, ctx, domainNames)
+}
+
+func (v *fakeVerifier) VerifyBatch(_ int, ctx context.Context, domainNames []string) []verifydomain.VerificationResult {
+	results := make([]verifydomain.VerificationResult, len(domainNames))
+	for i, d := range domainNames {
+		results[i] = v.Verify(ctx, d)
+	}
+	return results
+}
+
+func newTestRepositories(t *testing.T) (watch.Repository, registration.Repository) {
+	t.Helper()
+
+	conn, err := sqlite.GetConnection(sqlite.DefaultOptions(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return watch.NewRepository(conn), registration.NewRepository(conn)
+}
+
+func TestSync(t *testing.T) {
+	watchRepo, registrationRepo := newTestRepositories(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, reg := range []registration.Registration{
+		{Domain: "pending.test", Statuses: []string{registration.StatusPendingDelete}, LastChangedAt: at(-2 * day)},
+		{Domain: "expiring.test", Statuses: []string{"ok"}, ExpiresAt: at(10 * day)},
+		{Domain: "renewed.test", Statuses: []string{"ok"}, ExpiresAt: at(365 * day)},
+	} {
+		reg.CheckedAt = now
+		if err := registrationRepo.SaveRegistration(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := New(watchRepo, registrationRepo, &fakeVerifier{}, 30*day)
+	n, err := u.Sync(now)
+	if err != nil || n != 2 {
+		t.Fatalf("Sync() = %d, %v, want 2, nil", n, err)
+	}
+
+	entries, err := watchRepo.GetEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []watch.Entry{
+		// three days out, checked every 6 hours
+		{Domain: "pending.test", Reason: watch.ReasonPendingDelete, EstimatedDropAt: at(3 * day), NextCheckAt: now.Add(6 * time.Hour)},
+		{Domain: "expiring.test", Reason: watch.ReasonExpiring, EstimatedDropAt: at(90 * day), NextCheckAt: now.Add(day)},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("watchlist = %+v, want %+v", entries, want)
+	}
+	for i, w := range want {
+		got := entries[i]
+		if got.Domain != w.Domain || got.Reason != w.Reason || !got.EstimatedDropAt.Equal(*w.EstimatedDropAt) || !got.NextCheckAt.Equal(w.NextCheckAt) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestRecheck(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	drop := now.Add(3 * day)
+	entry := watch.Entry{
+		Domain:          "example.test",
+		Reason:          watch.ReasonPendingDelete,
+		EstimatedDropAt: &drop,
+		NextCheckAt:     now,
+		Checks:          2,
+		AddedAt:         now.Add(-day),
+	}
+	result := func(availability domaincheck.Availability) verifydomain.VerificationResult {
+		return verifydomain.VerificationResult{CheckedDomain: domaincheck.DomainCheck{Availability: &availability}}
+	}
+
+	tests := []struct {
+		name   string
+		reg    *registration.Registration // saved before the recheck
+		result verifydomain.VerificationResult
+		// the event emitted, or the entry it's left with
+		wantEvent  watch.EventKind
+		wantReason watch.Reason
+		wantDrop   time.Time
+	}{
+		{
+			name:      "dropped",
+			result:    result(domaincheck.AvailabilityAvailable),
+			wantEvent: watch.EventDropped,
+		},
+		{
+			name:      "renewed",
+			reg:       &registration.Registration{Statuses: []string{"ok"}, ExpiresAt: &[]time.Time{now.Add(365 * day)}[0]},
+			result:    result(domaincheck.AvailabilityRegistered),
+			wantEvent: watch.EventRenewed,
+		},
+		{
+			name:       "moved on to pending delete",
+			reg:        &registration.Registration{Statuses: []string{registration.StatusPendingDelete}, LastChangedAt: &[]time.Time{now.Add(-4 * day)}[0]},
+			result:     result(domaincheck.AvailabilityRegistered),
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   now.Add(day),
+		},
+		{
+			// rdap couldn't be asked so the last registration stands
+			name:       "registered without a registration",
+			result:     result(domaincheck.AvailabilityRegistered),
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   drop,
+		},
+		{
+			name:       "inconclusive",
+			result:     result(domaincheck.AvailabilityUnknown),
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   drop,
+		},
+		{
+			name:       "failed",
+			result:     verifydomain.VerificationResult{Err: errors.New("connection reset")},
+			wantReason: watch.ReasonPendingDelete,
+			wantDrop:   drop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchRepo, registrationRepo := newTestRepositories(t)
+			if err := watchRepo.SaveEntry(entry); err != nil {
+				t.Fatal(err)
+			}
+			if tt.reg != nil {
+				reg := *tt.reg
+				reg.Domain, reg.CheckedAt = entry.Domain, now
+				if err := registrationRepo.SaveRegistration(reg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			u := &usecases{watchRepo: watchRepo, registrationRepo: registrationRepo, expiringWithin: 30 * day}
+
+			var events []watch.Event
+			if err := u.recheck(entry, tt.result, now, func(e watch.Event) { events = append(events, e) }); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := watchRepo.GetEntries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantEvent != "" {
+				want := watch.Event{Domain: entry.Domain, Kind: tt.wantEvent, At: now}
+				if len(events) != 1 || events[0] != want {
+					t.Errorf("events = %+v, want %+v", events, want)
+				}
+				if len(entries) != 0 {
+					t.Errorf("watchlist = %+v, want it empty", entries)
+				}
+				return
+			}
+
+			if len(events) != 0 {
+				t.Errorf("events = %+v, want none", events)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("watchlist = %+v, want just %s", entries, entry.Domain)
+			}
+			got := entries[0]
+			if got.Reason != tt.wantReason || got.EstimatedDropAt == nil || !got.EstimatedDropAt.Equal(tt.wantDrop) {
+				t.Errorf("reason %q dropping at %v, want %q at %v", got.Reason, got.EstimatedDropAt, tt.wantReason, tt.wantDrop)
+			}
+			if want := nextCheck(now, &tt.wantDrop); !got.NextCheckAt.Equal(want) {
+				t.Errorf("next check at %v, want %v", got.NextCheckAt, want)
+			}
+			if got.Checks != entry.Checks+1 || got.LastCheckedAt == nil || !got.LastCheckedAt.Equal(now) {
+				t.Errorf("checks = %d last at %v, want %d at %v", got.Checks, got.LastCheckedAt, entry.Checks+1, now)
+			}
+		})
+	}
+}
+
+func TestCheckDue(t *testing.T) {
+	watchRepo, registrationRepo := newTestRepositories(t)
+	// CheckDue goes by the clock, so the entries are due around the real now
+	now := time.Now()
+	drop := now.Add(3 * day)
+
+	for _, e := range []watch.Entry{
+		{Domain: "dropped.test", NextCheckAt: now.Add(-time.Hour)},
+		{Domain: "renewed.test", NextCheckAt: now.Add(-time.Minute)},
+		{Domain: "inconclusive.test", NextCheckAt: now.Add(-time.Second)},
+		{Domain: "later.test", NextCheckAt: now.Add(time.Hour)},
+	} {
+		e.Reason, e.EstimatedDropAt, e.AddedAt = watch.ReasonPendingDelete, &drop, now
+		if err := watchRepo.SaveEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verifier := &fakeVerifier{
+		registrationRepo: registrationRepo,
+		availability: map[string]domaincheck.Availability{
+			"dropped.test": domaincheck.AvailabilityAvailable,
+			"renewed.test": domaincheck.AvailabilityRegistered,
+		},
+		registrations: map[string]registration.Registration{
+			"renewed.test": {Domain: "renewed.test", Statuses: []string{"ok"}, ExpiresAt: &[]time.Time{now.Add(365 * day)}[0], CheckedAt: now},
+		},
+	}
+	u := New(watchRepo, registrationRepo, verifier, 30*day)
+
+	var mu sync.Mutex
+	events := make(map[string]watch.EventKind)
+	err := u.CheckDue(2, context.Background(), func(e watch.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events[e.Domain] = e.Kind
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(verifier.asked)
+	if want := []string{"dropped.test", "inconclusive.test", "renewed.test"}; !slices.Equal(verifier.asked, want) {
+		t.Errorf("verified %v, want only the due domains %v", verifier.asked, want)
+	}
+	if len(events) != 2 || events["dropped.test"] != watch.EventDropped || events["renewed.test"] != watch.EventRenewed {
+		t.Errorf("events = %v, want dropped.test dropped and renewed.test renewed", events)
+	}
+
+	recorded, err := watchRepo.GetEvents(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Errorf("recorded events = %+v, want 2", recorded)
+	}
+
+	entries, err := watchRepo.GetEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, e := range entries {
+		remaining = append(remaining, e.Domain)
+		switch e.Domain {
+		case "inconclusive.test":
+			// rescheduled to try again
+			if e.Checks != 1 || !e.NextCheckAt.After(now) {
+				t.Errorf("inconclusive.test = %d checks next at %v, want 1 check and rescheduled", e.Checks, e.NextCheckAt)
+			}
+		case "later.test":
+			if e.Checks != 0 {
+				t.Errorf("later.test was checked %d times before it was due", e.Checks)
+			}
+		}
+	}
+	slices.Sort(remaining)
+	if want := []string{"inconclusive.test", "later.test"}; !slices.Equal(remaining, want) {
+		t.Errorf("watchlist = %v, want %v", remaining, want)
+	}
+}
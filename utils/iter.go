@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"iter"
+)
+
+// ConcatSeq streams each sequence one after another
+func ConcatSeq[T any](seqs ...iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, seq := range seqs {
+			for v := range seq {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// TakeUntilErr streams the values of seq until it yields an error, which is stored in err for the caller to check once
+// the stream is done
+func TakeUntilErr[T any](seq iter.Seq2[T, error], err *error) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v, e := range seq {
+			if e != nil {
+				*err = e
+				return
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"time"
 )
 
-// format as RFC3339 in UTC, which SQLite accepts as DATETIME TEXT
+/** SQLiteDTLayout is RFC3339 with the fraction always 9 digits wide, so timestamps compare and sort correctly as TEXT.
+ * RFC3339Nano trims trailing zeros, which puts "05.5Z" before "05Z".
+ */
+const SQLiteDTLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// format as fixed width RFC3339 in UTC, which SQLite accepts as DATETIME TEXT
 func ToSQLiteDT(t *time.Time) any {
 	if t == nil {
 		return nil
 	}
-	return t.UTC().Format(time.RFC3339Nano)
+	return t.UTC().Format(SQLiteDTLayout)
 }